@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"os"
 	"strconv"
 
@@ -13,32 +13,9 @@ import (
 	"github.com/scionproto/scion/go/lib/addr"
 )
 
-type messageSizeReader struct {
-	size   int
-	reader io.Reader
-}
-
-func (r *messageSizeReader) Read(p []byte) (n int, err error) {
-	n, err = r.reader.Read(p)
-	r.size += n
-	return
-}
-
-type doublepipe struct {
-	io.Reader
-	io.Writer
-}
-
 func main() {
 	k, hops, enum := argsOrExit()
 
-	r1, w1 := io.Pipe()
-	r2, w2 := io.Pipe()
-	msr1 := &messageSizeReader{reader: r1}
-	msr2 := &messageSizeReader{reader: r2}
-	p1 := doublepipe{msr1, w2}
-	p2 := doublepipe{msr2, w1}
-
 	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
 	core1, _ := addr.IAFromString("1-ffaa:0:1000")
 	core2, _ := addr.IAFromString("2-ffaa:0:1")
@@ -72,10 +49,12 @@ func main() {
 		Filter: sfilter,
 	}
 
-	go func() { _, _ = server.NegotiateOver(p1) }()
-	_, _ = client.NegotiateOver(p2)
-
-	fmt.Println(msr1.size, msr2.size)
+	result, err := conpass.Simulate(context.Background(), client, server)
+	if err != nil {
+		fmt.Println("negotiation failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println(result.Bytes(segment.RoleInitiator), result.Bytes(segment.RoleResponder))
 }
 
 func argsOrExit() (int, int, string) {
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mblarer/conpass/filter"
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/pathpol"
+)
+
+// corpus is the JSON representation of a SegmentSet. Segments are given in
+// the format of segment.ParseSegment, optionally together with their type:
+//
+//	{
+//	  "src": "1-ffaa:0:1",
+//	  "dst": "1-ffaa:0:3",
+//	  "segments": [
+//	    "1-ffaa:0:1 1>1 1-ffaa:0:2",
+//	    {"segment": "1-ffaa:0:2 2>1 1-ffaa:0:3", "type": "down"}
+//	  ]
+//	}
+type corpus struct {
+	Src      string            `json:"src"`
+	Dst      string            `json:"dst"`
+	Segments []json.RawMessage `json:"segments"`
+}
+
+type typedSegment struct {
+	Segment string `json:"segment"`
+	Type    string `json:"type"`
+}
+
+// loadCorpus reads a SegmentSet from a JSON file.
+func loadCorpus(filename string) (segment.SegmentSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return segment.SegmentSet{}, err
+	}
+	c := corpus{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return segment.SegmentSet{}, fmt.Errorf("%s: %s", filename, err)
+	}
+	segset := segment.SegmentSet{Segments: make([]segment.Segment, 0, len(c.Segments))}
+	if segset.SrcIA, err = addr.IAFromString(c.Src); err != nil {
+		return segment.SegmentSet{}, fmt.Errorf("%s: invalid src: %s", filename, err)
+	}
+	if segset.DstIA, err = addr.IAFromString(c.Dst); err != nil {
+		return segment.SegmentSet{}, fmt.Errorf("%s: invalid dst: %s", filename, err)
+	}
+	for i, raw := range c.Segments {
+		ts := typedSegment{}
+		if err := json.Unmarshal(raw, &ts.Segment); err != nil {
+			if err := json.Unmarshal(raw, &ts); err != nil {
+				return segment.SegmentSet{}, fmt.Errorf("%s: segment %d: %s", filename, i, err)
+			}
+		}
+		seg, err := segment.ParseSegment(ts.Segment)
+		if err != nil {
+			return segment.SegmentSet{}, fmt.Errorf("%s: segment %d: %s", filename, i, err)
+		}
+		if ts.Type != "" {
+			literal := seg.(segment.Literal)
+			if literal.Type, err = segment.TypeFromString(ts.Type); err != nil {
+				return segment.SegmentSet{}, fmt.Errorf("%s: segment %d: %s", filename, i, err)
+			}
+			seg = literal
+		}
+		segset.Segments = append(segset.Segments, seg)
+	}
+	return segset, nil
+}
+
+// loadFilter reads a segment.Filter from a JSON file, whose kind is detected
+// from its content: an object with a "pipeline" is a pipeline configuration,
+// an object with "routes" or a "default" is a rule table, and anything else
+// is a path policy, see loadPathPolicy.
+func loadFilter(filename string) (segment.Filter, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]json.RawMessage)
+	if json.Unmarshal(data, &keys) == nil {
+		if _, ok := keys["pipeline"]; ok {
+			return filter.Load(filename)
+		}
+		_, hasRoutes := keys["routes"]
+		_, hasDefault := keys["default"]
+		if hasRoutes || hasDefault {
+			return filter.LoadRouter(filename)
+		}
+	}
+	policy, err := loadPathPolicy(filename)
+	if err != nil {
+		return nil, err
+	}
+	return filter.FromPolicy(policy), nil
+}
+
+// loadPathPolicy reads a pathpol.Policy from a JSON file, which contains
+// either an ACL, i.e., a list, a sequence, i.e., a string, or a path policy,
+// i.e., an object, see filter.LoadPolicy.
+func loadPathPolicy(filename string) (*pathpol.Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s: file is empty", filename)
+	}
+	switch data[0] {
+	case '[':
+		acl := new(pathpol.ACL)
+		if err := json.Unmarshal(data, acl); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		return &pathpol.Policy{ACL: acl}, nil
+	case '"':
+		sequence := new(pathpol.Sequence)
+		if err := json.Unmarshal(data, sequence); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		return &pathpol.Policy{Sequence: sequence}, nil
+	}
+	return filter.LoadPolicy(filename)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mblarer/conpass/filter"
+	"github.com/scionproto/scion/go/lib/pathpol"
+)
+
+// policyDiagnose implements "conpass policy diagnose", which explains why the
+// path policies of two agents accept no common path for a set of candidate
+// segments, and suggests a minimum set of rules to relax.
+func policyDiagnose(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("policy diagnose", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	clientPolicy := flags.String("initiator", "", "path policy of the initiator (default: accept all)")
+	serverPolicy := flags.String("responder", "", "path policy of the responder (default: accept all)")
+	verbose := flags.Bool("v", false, "print the blocking rules of every path")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: conpass policy diagnose [flags] <corpus>")
+		fmt.Fprintln(flags.Output(), "\nThe policies are ACL, sequence or path policy files.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected a corpus")
+	}
+	candidates, err := loadCorpus(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var initiator, responder *pathpol.Policy
+	if *clientPolicy != "" {
+		if initiator, err = loadPathPolicy(*clientPolicy); err != nil {
+			return err
+		}
+	}
+	if *serverPolicy != "" {
+		if responder, err = loadPathPolicy(*serverPolicy); err != nil {
+			return err
+		}
+	}
+
+	diagnosis := filter.DiagnosePolicies(candidates, initiator, responder)
+	if len(diagnosis.Paths) == 0 {
+		fmt.Fprintln(w, "no end-to-end path can be constructed from the candidate segments")
+		return nil
+	}
+	if len(diagnosis.Relaxation) == 0 {
+		fmt.Fprintf(w, "path is accepted by both agents:\n  %s\n", diagnosis.Unblocked)
+		return nil
+	}
+
+	blocked := make(map[filter.Rule]int)
+	rules := make([]filter.Rule, 0)
+	for _, pd := range diagnosis.Paths {
+		if *verbose {
+			fmt.Fprintf(w, "%s\n", pd.Path)
+		}
+		for _, rule := range pd.Blocking {
+			if *verbose {
+				fmt.Fprintf(w, "  blocked by %s\n", rule)
+			}
+			if blocked[rule] == 0 {
+				rules = append(rules, rule)
+			}
+			blocked[rule]++
+		}
+	}
+	fmt.Fprintf(w, "all %d paths are blocked by:\n", len(diagnosis.Paths))
+	for _, rule := range rules {
+		fmt.Fprintf(w, "  %s: %d paths\n", rule, blocked[rule])
+	}
+	fmt.Fprintf(w, "a minimum relaxation of %d rule(s) accepts the path\n  %s\n", len(diagnosis.Relaxation), diagnosis.Unblocked)
+	for _, rule := range diagnosis.Relaxation {
+		fmt.Fprintf(w, "  relax %s\n", rule)
+	}
+	return nil
+}
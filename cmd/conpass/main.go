@@ -0,0 +1,49 @@
+// Command conpass provides offline tools for CONPASS policies and segment
+// corpora, e.g., to evaluate a policy change before it is deployed.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: conpass <command> [arguments]
+
+commands:
+  policy eval      evaluate a policy against a segment corpus
+  policy diagnose  explain why two path policies accept no common path
+  simulate         simulate a negotiation between two policies
+
+Run "conpass <command> -h" for the arguments of a command.
+`
+
+// errDifferent is returned by commands that compare results, like diff, if
+// the results differ. It makes the command exit with status 1.
+var errDifferent = errors.New("results differ")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	switch {
+	case err == nil:
+	case errors.Is(err, errDifferent):
+		os.Exit(1)
+	default:
+		fmt.Fprintln(os.Stderr, "conpass:", err)
+		os.Exit(2)
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	switch {
+	case len(args) >= 2 && args[0] == "policy" && args[1] == "eval":
+		return policyEval(args[2:], w)
+	case len(args) >= 2 && args[0] == "policy" && args[1] == "diagnose":
+		return policyDiagnose(args[2:], w)
+	case len(args) >= 1 && args[0] == "simulate":
+		return simulate(args[1:], w)
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return errors.New("unknown command")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mblarer/conpass/filter"
+	"github.com/mblarer/conpass/segment"
+)
+
+// policyEval implements "conpass policy eval", which applies a policy to a
+// segment corpus in the same way as an Initiator or Responder would, and
+// prints the accepted and rejected segments together with the reasons.
+func policyEval(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("policy eval", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	role := flags.String("role", "responder", "role in which the policy is applied: initiator or responder")
+	identity := flags.String("identity", "", "authenticated identity of the peer, e.g., for rule tables")
+	diff := flags.String("diff", "", "second policy to compare the first policy with")
+	trace := flags.Bool("trace", false, "print the decisions of all stages")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: conpass policy eval [flags] <policy> <corpus>")
+		fmt.Fprintln(flags.Output(), "\nThe policy is an ACL, sequence, path policy, pipeline or rule table file.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a policy and a corpus")
+	}
+	info := segment.NegotiationInfo{PeerIdentity: *identity, Transport: "offline"}
+	switch *role {
+	case "initiator":
+		info.Role = segment.RoleInitiator
+	case "responder":
+		info.Role = segment.RoleResponder
+	default:
+		return fmt.Errorf("invalid role %q", *role)
+	}
+	segset, err := loadCorpus(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	result, err := evalPolicy(flags.Arg(0), segset, info)
+	if err != nil {
+		return err
+	}
+	if *diff == "" {
+		writeResult(w, segset, result)
+		if *trace {
+			fmt.Fprintln(w)
+			return result.Trace.WriteReport(w)
+		}
+		return nil
+	}
+	other, err := evalPolicy(*diff, segset, info)
+	if err != nil {
+		return err
+	}
+	if !writeDiff(w, result, other) {
+		return errDifferent
+	}
+	return nil
+}
+
+// evalPolicy applies a policy to a SegmentSet with tracing enabled. The
+// filter is applied like in the first filtering of the given role.
+func evalPolicy(filename string, segset segment.SegmentSet, info segment.NegotiationInfo) (segment.SegmentSet, error) {
+	policy, err := loadFilter(filename)
+	if err != nil {
+		return segment.SegmentSet{}, err
+	}
+	stage := "initial"
+	info.PeerIA, info.Round = segset.DstIA, 1
+	if info.Role == segment.RoleResponder {
+		stage = "responder"
+		info.PeerIA, info.Round = segset.SrcIA, 2
+	}
+	segset.Trace = segment.NewTrace()
+	return segment.ApplyFilter(context.Background(), info, filter.Stage(stage, policy), segset), nil
+}
+
+// writeResult prints the segments of the corpus with their verdicts, followed
+// by the segments that the policy added, e.g., enumerated paths.
+func writeResult(w io.Writer, segset, result segment.SegmentSet) {
+	accepted, rejected, added := 0, 0, 0
+	for _, seg := range segset.Segments {
+		if result.Contains(seg, segment.Segment.Fingerprint) {
+			fmt.Fprintf(w, "accept  %s\n", seg)
+			accepted++
+		} else {
+			fmt.Fprintf(w, "reject  %s%s\n", seg, rejection(result.Trace, seg))
+			rejected++
+		}
+	}
+	for _, seg := range result.Segments {
+		if !segset.Contains(seg, segment.Segment.Fingerprint) {
+			fmt.Fprintf(w, "new     %s\n", seg)
+			added++
+		}
+	}
+	fmt.Fprintf(w, "%d accepted, %d rejected, %d new\n", accepted, rejected, added)
+}
+
+// writeDiff prints the segments that are only accepted by the first policy,
+// prefixed with "-", and the segments that are only accepted by the second
+// policy, prefixed with "+", together with the reason why the other policy
+// rejected them. It reports whether the results are equal.
+func writeDiff(w io.Writer, result, other segment.SegmentSet) bool {
+	equal := true
+	for _, seg := range result.Difference(other, segment.InterfaceFingerprint).Segments {
+		fmt.Fprintf(w, "- %s%s\n", seg, rejection(other.Trace, seg))
+		equal = false
+	}
+	for _, seg := range other.Difference(result, segment.InterfaceFingerprint).Segments {
+		fmt.Fprintf(w, "+ %s%s\n", seg, rejection(result.Trace, seg))
+		equal = false
+	}
+	return equal
+}
+
+// rejection returns the innermost stage that rejected a segment, together
+// with the reason, if any.
+func rejection(trace *segment.Trace, seg segment.Segment) string {
+	for _, decision := range trace.Decisions(seg) {
+		if decision.Verdict != segment.VerdictRejected {
+			continue
+		}
+		if decision.Reason != "" {
+			return fmt.Sprintf("  [%s: %s]", decision.Stage, decision.Reason)
+		}
+		return fmt.Sprintf("  [%s]", decision.Stage)
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCorpus = `{
+	"src": "19-ffaa:0:1303",
+	"dst": "17-ffaa:0:1107",
+	"segments": [
+		"19-ffaa:0:1303 1>1 19-ffaa:0:1302",
+		{"segment": "19-ffaa:0:1302 2>1 17-ffaa:0:1108", "type": "core"},
+		"19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108",
+		"17-ffaa:0:1108 3>2 17-ffaa:0:1107"
+	]
+}`
+
+func TestPolicyEval(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"corpus.json":   testCorpus,
+		"acl.json":      `["- 17-ffaa:0:1101", "+"]`,
+		"sequence.json": `"0* 17-ffaa:0:1101 0*"`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	var out strings.Builder
+	err := run([]string{"policy", "eval", path("acl.json"), path("corpus.json")}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`reject  19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108  [responder: 17-ffaa:0:1101#1 matches "- 17-ffaa:0:1101#0"]`,
+		"3 accepted, 1 rejected, 0 new",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("want output containing %q, have:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	err = run([]string{"policy", "eval", "-diff", path("acl.json"), path("acl.json"), path("corpus.json")}, &out)
+	if err != nil || out.Len() != 0 {
+		t.Errorf("want no difference, have: %v\n%s", err, out.String())
+	}
+
+	out.Reset()
+	err = run([]string{"policy", "eval", "-diff", path("sequence.json"), path("acl.json"), path("corpus.json")}, &out)
+	if !errors.Is(err, errDifferent) {
+		t.Errorf("want errDifferent, have: %v", err)
+	}
+	want := "+ [(19-ffaa:0:1303 1>1 19-ffaa:0:1302), (19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108), (17-ffaa:0:1108 3>2 17-ffaa:0:1107)]"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("want output containing %q, have:\n%s", want, out.String())
+	}
+}
+
+func TestPolicyDiagnose(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"corpus.json":   testCorpus,
+		"acl.json":      `["- 17-ffaa:0:1108", "+"]`,
+		"sequence.json": `"0* 17-ffaa:0:1101 0*"`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	var out strings.Builder
+	args := []string{"policy", "diagnose", "-initiator", path("acl.json"), "-responder", path("sequence.json"), path("corpus.json")}
+	if err := run(args, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`initiator ACL entry 0 "- 17-ffaa:0:1108#0": 2 paths`,
+		`responder sequence "0* 17-ffaa:0:1101 0*": 1 paths`,
+		`a minimum relaxation of 1 rule(s) accepts the path`,
+		`relax initiator ACL entry 0 "- 17-ffaa:0:1108#0"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("want output containing %q, have:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mblarer/conpass"
+	"github.com/mblarer/conpass/filter"
+	"github.com/mblarer/conpass/segment"
+)
+
+// simulate implements "conpass simulate", which negotiates between an
+// Initiator and a Responder with the given policies over in-memory pipes and
+// prints the agreed segments, the exchanged messages and the number of rounds.
+func simulate(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	clientPolicy := flags.String("initiator", "", "policy of the initiator (default: accept all)")
+	serverPolicy := flags.String("responder", "", "policy of the responder (default: accept all)")
+	trace := flags.Bool("trace", false, "print the decisions of both agents")
+	batch := flags.Int("batch", 0, "disclose the segments of the initiator progressively in batches of this size (default: all at once)")
+	budget := flags.Int("budget", 0, "maximum number of segment literals that the initiator discloses progressively (default: unlimited)")
+	target := flags.Int("target", 1, "number of agreed segments after which the initiator stops disclosing")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: conpass simulate [flags] <corpus>")
+		fmt.Fprintln(flags.Output(), "\nThe corpus is the initial segment set of the initiator.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected a corpus")
+	}
+	segset, err := loadCorpus(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	client := conpass.Initiator{InitialSegset: segset, Filter: filter.FromFilters(), Trace: *trace}
+	if *batch > 0 {
+		client.Disclosure = &conpass.Disclosure{BatchSize: *batch, Budget: *budget, Target: *target}
+		// The trace of the initiator counts the disclosed segment literals.
+		client.Trace = true
+	}
+	server := conpass.Responder{Filter: filter.FromFilters(), Trace: *trace}
+	if *clientPolicy != "" {
+		if client.Filter, err = loadFilter(*clientPolicy); err != nil {
+			return err
+		}
+	}
+	if *serverPolicy != "" {
+		if server.Filter, err = loadFilter(*serverPolicy); err != nil {
+			return err
+		}
+	}
+	result, err := conpass.Simulate(context.Background(), client, server)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "agreed on %d segments:\n", len(result.Initiator.Segments))
+	for _, seg := range result.Initiator.Segments {
+		fmt.Fprintf(w, "  %s\n", seg)
+	}
+	if *batch > 0 {
+		literals := make(map[string]bool)
+		for _, seg := range segset.Segments {
+			for _, literal := range segment.Literals(seg) {
+				literals[segment.InterfaceFingerprint(literal)] = true
+			}
+		}
+		fmt.Fprintf(w, "disclosed %d of %d segment literals\n", result.Initiator.Trace.Disclosed(), len(literals))
+	}
+	fmt.Fprintf(w, "%d rounds:\n", result.Rounds())
+	for i, message := range result.Messages {
+		fmt.Fprintf(w, "  %d. %s: %d bytes\n", i+1, message.Sender, message.Size)
+	}
+	if *trace {
+		fmt.Fprintln(w, "\ninitiator trace:")
+		if err := result.Initiator.Trace.WriteReport(w); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "\nresponder trace:")
+		return result.Responder.Trace.WriteReport(w)
+	}
+	return nil
+}
@@ -1,8 +1,13 @@
 package conpass
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/mblarer/conpass/filter"
 	"github.com/mblarer/conpass/segment"
@@ -245,12 +250,466 @@ func TestNegotiationCyclicPath(t *testing.T) {
 	test(segset, cfilter, sfilter, want, t)
 }
 
+func TestNegotiationBidirectional(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("17-ffaa:0:1108 2>1 17-ffaa:0:1102 2>1 17-ffaa:0:1107"),
+	}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1107")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	seq := new(pathpol.Sequence)
+	_ = seq.UnmarshalJSON([]byte(`"19* 17*"`))
+	sfilter := filter.FromFilters(filter.SrcDstPathEnumerator(), filter.FromSequence(*seq))
+	client := Initiator{InitialSegset: segset, Filter: filter.FromFilters()}
+	server := Responder{Filter: sfilter, Bidirectional: true}
+	want := []segment.Segment{}
+	testAgents(client, server, want, t)
+
+	acl := new(pathpol.ACL)
+	_ = acl.UnmarshalJSON([]byte(`["- 17-ffaa:0:1102", "+"]`))
+	server = Responder{Filter: filter.FromACL(*acl), Bidirectional: true}
+	want = segments[:2]
+	testAgents(client, server, want, t)
+
+	// The hop at 19-ffaa:0:1302 is allowed from interface 1 to 2, but denied
+	// in the reverse direction from interface 2 to 1.
+	_ = acl.UnmarshalJSON([]byte(`["- 19-ffaa:0:1302#2,1", "+"]`))
+	sfilter = filter.FromFilters(filter.SrcDstPathEnumerator(), filter.FromACL(*acl))
+	server = Responder{Filter: sfilter}
+	want = []segment.Segment{segment.FromSegments(segments...)}
+	testAgents(client, server, want, t)
+	server.Bidirectional = true
+	want = []segment.Segment{}
+	testAgents(client, server, want, t)
+}
+
+func TestNegotiationTopK(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1303 2>1 19-ffaa:0:1301 2>2 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("17-ffaa:0:1108 2>1 17-ffaa:0:1102 2>1 17-ffaa:0:1107"),
+		segment.FromString("17-ffaa:0:1108 3>1 17-ffaa:0:1107"),
+	}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1107")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	cfilter := filter.TopK(3, segment.HopCount)
+	sfilter := filter.FromFilters(filter.TopK(2, segment.HopCount))
+	want := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302 2>1 17-ffaa:0:1108 3>1 17-ffaa:0:1107"),
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302 2>1 17-ffaa:0:1108 2>1 17-ffaa:0:1102 2>1 17-ffaa:0:1107"),
+	}
+	test(segset, cfilter, sfilter, want, t)
+}
+
+func TestNegotiationTrace(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+	}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	acl := new(pathpol.ACL)
+	_ = acl.UnmarshalJSON([]byte(`["- 17-ffaa:0:1101#1", "+"]`))
+	client := Initiator{InitialSegset: segset, Filter: filter.FromFilters(), Trace: true}
+	server := Responder{Filter: filter.FromFilters(filter.FromACL(*acl)), Trace: true}
+	want := segments[:2]
+	csegset, ssegset := testAgents(client, server, want, t)
+
+	assertDecisions := func(trace *segment.Trace, seg segment.Segment, want []segment.Decision) {
+		have := trace.Decisions(seg)
+		if len(have) != len(want) {
+			t.Fatalf("want %d decisions, have %d: %v", len(want), len(have), have)
+		}
+		for i := range have {
+			if have[i] != want[i] {
+				t.Errorf("want: %v, have: %v", want[i], have[i])
+			}
+		}
+	}
+	assertDecisions(csegset.Trace, segments[2], []segment.Decision{
+		{Stage: "initial", Verdict: segment.VerdictAccepted},
+		{Stage: "responder", Verdict: segment.VerdictRejected},
+	})
+	assertDecisions(ssegset.Trace, segments[2], []segment.Decision{
+		{Stage: "responder/acl", Verdict: segment.VerdictRejected, Reason: `17-ffaa:0:1101#1 matches "- 17-ffaa:0:1101#1"`},
+		{Stage: "responder", Verdict: segment.VerdictRejected},
+	})
+	assertDecisions(ssegset.Trace, segments[0], []segment.Decision{
+		{Stage: "responder/acl", Verdict: segment.VerdictAccepted},
+		{Stage: "responder", Verdict: segment.VerdictAccepted},
+	})
+	for _, seg := range want {
+		assertDecisions(csegset.Trace, seg, []segment.Decision{
+			{Stage: "initial", Verdict: segment.VerdictAccepted},
+			{Stage: "responder", Verdict: segment.VerdictAccepted},
+			{Stage: "final", Verdict: segment.VerdictAccepted},
+		})
+	}
+}
+
+func TestNegotiationPeerAware(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+	}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	infos := make(chan segment.NegotiationInfo, 3)
+	recorder := filter.FromContextFunc(func(ctx context.Context, info segment.NegotiationInfo, ss segment.SegmentSet) segment.SegmentSet {
+		infos <- info
+		return ss
+	})
+	client := Initiator{InitialSegset: segset, Filter: recorder, Options: map[string]string{"app": "test"}}
+	byISD := filter.ForPeer(func(info segment.NegotiationInfo) segment.Filter {
+		if info.PeerIA.I == 19 {
+			return filter.FromFilters()
+		}
+		return nil
+	})
+	server := Responder{Filter: filter.FromFilters(recorder, byISD)}
+	testAgents(client, server, segments, t)
+	close(infos)
+	rounds := map[int]segment.NegotiationInfo{}
+	for info := range infos {
+		rounds[info.Round] = info
+	}
+	for round, want := range map[int]segment.NegotiationInfo{
+		1: {PeerIA: dstIA, Round: 1, Role: segment.RoleInitiator},
+		2: {PeerIA: srcIA, Round: 2, Role: segment.RoleResponder},
+		3: {PeerIA: dstIA, Round: 3, Role: segment.RoleInitiator},
+	} {
+		have := rounds[round]
+		if have.PeerIA != want.PeerIA || have.Role != want.Role || have.Round != want.Round {
+			t.Errorf("round %d: want: %+v, have: %+v", round, want, have)
+		}
+		if want.Role == segment.RoleInitiator && have.Options["app"] != "test" {
+			t.Errorf("round %d: options not passed to filter: %v", round, have.Options)
+		}
+	}
+
+	segset.SrcIA, segset.DstIA = dstIA, srcIA
+	client = Initiator{InitialSegset: segset, Filter: filter.FromFilters()}
+	server = Responder{Filter: byISD}
+	testAgents(client, server, []segment.Segment{}, t)
+}
+
+type tlsStream struct {
+	io.ReadWriter
+	state tls.ConnectionState
+}
+
+func (s tlsStream) ConnectionState() tls.ConnectionState {
+	return s.state
+}
+
+func TestNegotiationInfoVerifiedIdentity(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}}
+	unverified := tlsStream{state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+	if info := negotiationInfo(unverified, segment.RoleResponder, nil); info.PeerIdentity != "" || info.Transport != "tls" {
+		t.Errorf("unverified certificate yields identity %q over %q", info.PeerIdentity, info.Transport)
+	}
+	verified := unverified
+	verified.state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	if info := negotiationInfo(verified, segment.RoleResponder, nil); info.PeerIdentity != "client" {
+		t.Errorf("want identity %q, have %q", "client", info.PeerIdentity)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+	}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	acl := new(pathpol.ACL)
+	_ = acl.UnmarshalJSON([]byte(`["- 17-ffaa:0:1101", "+"]`))
+	client := Initiator{InitialSegset: segset, Filter: filter.SrcDstPathEnumerator()}
+	server := Responder{Filter: filter.FromACL(*acl)}
+	result, err := Simulate(context.Background(), client, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []segment.Segment{segment.FromSegments(segments[0], segments[1])}
+	assertEqual(result.Initiator.Segments, want, t)
+	assertEqual(result.Responder.Segments, want, t)
+	if result.Rounds() != 2 {
+		t.Fatalf("want 2 rounds, have %d", result.Rounds())
+	}
+	if result.Messages[0].Sender != segment.RoleInitiator || result.Messages[1].Sender != segment.RoleResponder {
+		t.Errorf("messages have wrong senders: %v", result.Messages)
+	}
+	if result.Bytes(segment.RoleInitiator) <= result.Bytes(segment.RoleResponder) {
+		t.Errorf("want request larger than response, have %v", result.Messages)
+	}
+}
+
+func TestSimulateFailure(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+	}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	// The private Responder fails to decode the request of the Initiator
+	// while the Initiator is still writing it.
+	client := Initiator{InitialSegset: segset, Filter: filter.FromFilters()}
+	server := Responder{Filter: filter.FromFilters(), Private: true}
+	done := make(chan error, 1)
+	go func() {
+		_, err := Simulate(context.Background(), client, server)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("want error, have nil")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("simulation did not terminate")
+	}
+}
+
+func TestNegotiationCounterOffer(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+	}
+	down := segment.FromString("17-ffaa:0:1108 2>1 17-ffaa:0:1102 2>1 17-ffaa:0:1107")
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1107")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	client := Initiator{InitialSegset: segset, Filter: filter.FromFilters()}
+	server := Responder{Filter: filter.SrcDstPathEnumerator(), SegmentStore: []segment.Segment{down}, Trace: true}
+	result, err := Simulate(context.Background(), client, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []segment.Segment{segment.FromSegments(segments[0], segments[1], down)}
+	assertEqual(result.Initiator.Segments, want, t)
+	assertEqual(result.Responder.Segments, []segment.Segment{}, t)
+	// Only the down segment of the counter-offer is new to the initiator.
+	if have := result.Responder.Trace.Disclosed(); have != 1 {
+		t.Errorf("want 1 segment literal disclosed by the responder, have %d", have)
+	}
+
+	acl := new(pathpol.ACL)
+	_ = acl.UnmarshalJSON([]byte(`["- 17-ffaa:0:1102", "+"]`))
+	client.Filter = filter.FromACL(*acl)
+	result, err = Simulate(context.Background(), client, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(result.Initiator.Segments, []segment.Segment{}, t)
+}
+
+func TestNegotiationJointRanking(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+	}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	direct := segment.FromSegments(segments[0], segments[1])
+	detour := segment.FromSegments(segments[0], segments[2])
+	prefer := func(directScore, detourScore float64) segment.Filter {
+		return filter.Prefer(func(seg segment.Segment) float64 {
+			if segment.InterfaceFingerprint(seg) == segment.InterfaceFingerprint(direct) {
+				return directScore
+			}
+			return detourScore
+		})
+	}
+	for name, tc := range map[string]struct {
+		aggregation segment.Aggregation
+		want        []segment.Segment
+	}{
+		"sum":  {segment.AggregateSum, []segment.Segment{detour, direct}},
+		"min":  {segment.AggregateMin, []segment.Segment{direct, detour}},
+		"nash": {segment.AggregateNash, []segment.Segment{direct, detour}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := Initiator{
+				InitialSegset: segset,
+				Filter:        filter.FromFilters(filter.SrcDstPathEnumerator(), prefer(3, 1)),
+				Aggregation:   tc.aggregation,
+			}
+			server := Responder{Filter: prefer(2, 5), Aggregation: tc.aggregation}
+			testAgents(client, server, tc.want, t)
+		})
+	}
+}
+
+func TestNegotiationProgressiveDisclosure(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+	}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	direct := segment.FromSegments(segments[0], segments[1])
+	detour := segment.FromSegments(segments[0], segments[2])
+	preferDetour := filter.Prefer(func(seg segment.Segment) float64 {
+		if segment.InterfaceFingerprint(seg) == segment.InterfaceFingerprint(detour) {
+			return 2
+		}
+		return 1
+	})
+	acl := new(pathpol.ACL)
+	_ = acl.UnmarshalJSON([]byte(`["- 17-ffaa:0:1101", "+"]`))
+	tests := []struct {
+		name       string
+		disclosure Disclosure
+		filter     segment.Filter
+		want       []segment.Segment
+		disclosed  int
+		rounds     int
+	}{
+		{"first batch accepted", Disclosure{BatchSize: 1}, filter.FromFilters(), []segment.Segment{detour}, 2, 3},
+		{"first batch rejected", Disclosure{BatchSize: 1}, filter.FromACL(*acl), []segment.Segment{direct}, 3, 5},
+		{"budget exhausted", Disclosure{BatchSize: 1, Budget: 2}, filter.FromACL(*acl), []segment.Segment{}, 2, 3},
+		{"target not reached", Disclosure{BatchSize: 1, Target: 2}, filter.FromFilters(), []segment.Segment{detour, direct}, 3, 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			disclosure := test.disclosure
+			client := Initiator{
+				InitialSegset: segset,
+				Filter:        filter.FromFilters(filter.SrcDstPathEnumerator(), preferDetour),
+				Disclosure:    &disclosure,
+				Trace:         true,
+			}
+			server := Responder{Filter: test.filter, Trace: true}
+			result, err := Simulate(context.Background(), client, server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(result.Initiator.Segments, test.want, t)
+			assertEqual(result.Responder.Segments, test.want, t)
+			if have := result.Initiator.Trace.Disclosed(); have != test.disclosed {
+				t.Errorf("want %d disclosed segment literals, have %d", test.disclosed, have)
+			}
+			// The responder only returns requested segments, whose literals
+			// the initiator already knows.
+			if have := result.Responder.Trace.Disclosed(); have != 0 {
+				t.Errorf("want no segment literals disclosed by the responder, have %d", have)
+			}
+			for _, seg := range result.Responder.Trace.Segments() {
+				decisions := 0
+				for _, decision := range result.Responder.Trace.Decisions(seg) {
+					if decision.Stage == "responder" {
+						decisions++
+					}
+				}
+				if decisions != 1 {
+					t.Errorf("want 1 responder decision about %s, have %d", seg, decisions)
+				}
+			}
+			if result.Rounds() != test.rounds {
+				t.Errorf("want %d rounds, have %d", test.rounds, result.Rounds())
+			}
+		})
+	}
+}
+
+func TestNegotiationPrivate(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+	}
+	other := segment.FromString("19-ffaa:0:1302 4>1 17-ffaa:0:1109 2>4 17-ffaa:0:1108")
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	acl := new(pathpol.ACL)
+	_ = acl.UnmarshalJSON([]byte(`["- 17-ffaa:0:1101", "+"]`))
+	client := Initiator{InitialSegset: segset, Filter: filter.SrcDstPathEnumerator(), Private: true}
+	server := Responder{
+		Filter:       filter.FromFilters(filter.SrcDstPathEnumerator(), filter.FromACL(*acl)),
+		SegmentStore: append([]segment.Segment{other}, segments...),
+		Private:      true,
+	}
+	result, err := Simulate(context.Background(), client, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []segment.Segment{segment.FromSegments(segments[0], segments[1])}
+	assertEqual(result.Initiator.Segments, want, t)
+	assertEqual(result.Responder.Segments, want, t)
+	if result.Rounds() != 3 {
+		t.Errorf("want 3 rounds, have %d", result.Rounds())
+	}
+
+	server.SegmentStore = []segment.Segment{segments[0], other}
+	result, err = Simulate(context.Background(), client, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(result.Initiator.Segments, []segment.Segment{}, t)
+	assertEqual(result.Responder.Segments, []segment.Segment{}, t)
+}
+
+func TestNegotiationConditions(t *testing.T) {
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+	}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	direct := segment.FromSegments(segments[0], segments[1])
+	detour := segment.FromSegments(segments[0], segments[2])
+	expired := time.Now().Add(-time.Hour).Truncate(time.Second)
+	valid := time.Now().Add(time.Hour).Truncate(time.Second)
+	client := Initiator{
+		InitialSegset: segset,
+		Filter: filter.FromFilters(filter.SrcDstPathEnumerator(), filter.Condition(func(seg segment.Segment) segment.Conditions {
+			return segment.Conditions{Bandwidth: 50e6}
+		})),
+	}
+	server := Responder{Filter: filter.Condition(func(seg segment.Segment) segment.Conditions {
+		if segment.InterfaceFingerprint(seg) == segment.InterfaceFingerprint(direct) {
+			return segment.Conditions{NotAfter: expired}
+		}
+		return segment.Conditions{NotAfter: valid, Bandwidth: 100e6, Priority: 2}
+	})}
+	want := []segment.Segment{detour}
+	csegset, ssegset := testAgents(client, server, want, t)
+	wantConditions := segment.Conditions{NotAfter: valid, Bandwidth: 50e6, Priority: 2}
+	for _, segset := range []segment.SegmentSet{csegset, ssegset} {
+		have := segment.ConditionsOf(segset.Conditions, segset.Segments[0])
+		if !have.NotAfter.Equal(wantConditions.NotAfter) || have.Bandwidth != wantConditions.Bandwidth || have.Priority != wantConditions.Priority {
+			t.Errorf("want: %+v, have: %+v", wantConditions, have)
+		}
+	}
+}
+
 func test(ss segment.SegmentSet, cf, sf segment.Filter, want []segment.Segment, t *testing.T) {
+	client := Initiator{InitialSegset: ss, Filter: cf}
+	server := Responder{Filter: sf}
+	testAgents(client, server, want, t)
+}
+
+func testAgents(client Initiator, server Responder, want []segment.Segment, t *testing.T) (segment.SegmentSet, segment.SegmentSet) {
 	r1, w1 := io.Pipe()
 	r2, w2 := io.Pipe()
 	p1, p2 := doublepipe{r1, w2}, doublepipe{r2, w1}
-	client := Initiator{InitialSegset: ss, Filter: cf}
-	server := Responder{Filter: sf}
 	channel := make(chan segment.SegmentSet, 1)
 	go func(c chan segment.SegmentSet, t *testing.T) {
 		ssegset, err := server.NegotiateOver(p1)
@@ -266,6 +725,7 @@ func test(ss segment.SegmentSet, cf, sf segment.Filter, want []segment.Segment,
 	ssegset := <-channel
 	assertEqual(csegset.Segments, want, t)
 	assertEqual(ssegset.Segments, want, t)
+	return csegset, ssegset
 }
 
 type doublepipe struct {
@@ -283,3 +743,27 @@ func assertEqual(have, want []segment.Segment, t *testing.T) {
 		}
 	}
 }
+
+func TestNegotiationSegmentTypes(t *testing.T) {
+	up := segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1301").(segment.Literal)
+	up.Type, up.Dir = segment.TypeUp, segment.DirAgainstConstruction
+	core := segment.FromString("19-ffaa:0:1301 2>1 17-ffaa:0:1101").(segment.Literal)
+	core.Type, core.Dir = segment.TypeCore, segment.DirAgainstConstruction
+	down := segment.FromString("17-ffaa:0:1101 2>1 17-ffaa:0:1107").(segment.Literal)
+	down.Type, down.Dir = segment.TypeDown, segment.DirConstruction
+	peering := segment.FromString("19-ffaa:0:1303 3>1 17-ffaa:0:1107").(segment.Literal)
+	peering.Type = segment.TypePeering
+	segments := []segment.Segment{up, core, down, peering}
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1107")
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	cfilter := filter.FromFilters()
+	sfilter := filter.FromLiteralPredicate(func(l segment.Literal) bool {
+		if l.Type == segment.TypeCore && l.SrcIA().I != 17 {
+			return false
+		}
+		return l.Type != segment.TypePeering && l.Dir != segment.DirUnspecified
+	})
+	want := []segment.Segment{up, down}
+	test(segset, cfilter, sfilter, want, t)
+}
@@ -0,0 +1,108 @@
+package conpass
+
+import (
+	"sort"
+
+	"github.com/mblarer/conpass/segment"
+)
+
+// Disclosure configures the progressive disclosure of the segments of an
+// Initiator. Instead of sending all segments that remain after its initial
+// filtering at once, the Initiator sends them in batches, in order of
+// decreasing preference, and stops as soon as enough paths have been agreed
+// on. This limits what the Responder learns about the upstream connectivity
+// of the Initiator at the cost of additional round trips. Both agents need to
+// support progressive disclosure.
+type Disclosure struct {
+	// BatchSize is the number of segments that are disclosed per request. It
+	// defaults to 1.
+	BatchSize int
+	// Budget is the maximum number of segment literals that are disclosed in
+	// total, i.e., the literals of which the disclosed segments consist,
+	// counting each literal once. Segments that would exceed the budget are
+	// not disclosed. If it is zero, all segments may be disclosed.
+	Budget int
+	// Target is the number of segments that are accepted by the final
+	// filtering of the Initiator after which no further segments are
+	// disclosed. It defaults to 1.
+	Target int
+}
+
+// batches splits the segments of a SegmentSet into the batches in which they
+// are disclosed, in order of decreasing preference, see
+// segment.SegmentSet.Preferences. A nil Disclosure discloses all segments in
+// a single batch.
+func (d *Disclosure) batches(segset segment.SegmentSet) [][]segment.Segment {
+	if d == nil {
+		return [][]segment.Segment{segset.Segments}
+	}
+	segments := append([]segment.Segment(nil), segset.Segments...)
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segment.Preference(segset.Preferences, segments[i]) > segment.Preference(segset.Preferences, segments[j])
+	})
+	if d.Budget > 0 {
+		known := literals{}
+		withinBudget := make([]segment.Segment, 0, len(segments))
+		for _, seg := range segments {
+			if len(known)+known.unknown(seg) <= d.Budget {
+				known.disclose([]segment.Segment{seg})
+				withinBudget = append(withinBudget, seg)
+			}
+		}
+		segments = withinBudget
+	}
+	size := d.BatchSize
+	if size <= 0 {
+		size = 1
+	}
+	batches := make([][]segment.Segment, 0)
+	for len(segments) > size {
+		batches = append(batches, segments[:size])
+		segments = segments[size:]
+	}
+	return append(batches, segments)
+}
+
+// done reports whether enough segments have been agreed on.
+func (d *Disclosure) done(agreed segment.SegmentSet) bool {
+	target := d.Target
+	if target <= 0 {
+		target = 1
+	}
+	return len(agreed.Segments) >= target
+}
+
+// literals is a set of segment literals, identified by their
+// InterfaceFingerprint, that have been exchanged in a negotiation. Both agents
+// count the segment literals that they disclose, since the literals of a
+// segment are sent along with it unless they have been exchanged before.
+type literals map[string]bool
+
+// unknown returns the number of literals of a segment that are not in the set.
+func (l literals) unknown(seg segment.Segment) int {
+	count := 0
+	seen := make(map[string]bool)
+	for _, literal := range segment.Literals(seg) {
+		key := segment.InterfaceFingerprint(literal)
+		if !l[key] && !seen[key] {
+			seen[key] = true
+			count++
+		}
+	}
+	return count
+}
+
+// disclose adds the literals of the given segments to the set and returns the
+// number of literals that were not in the set before.
+func (l literals) disclose(segments []segment.Segment) int {
+	count := 0
+	for _, seg := range segments {
+		for _, literal := range segment.Literals(seg) {
+			if key := segment.InterfaceFingerprint(literal); !l[key] {
+				l[key] = true
+				count++
+			}
+		}
+	}
+	return count
+}
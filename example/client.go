@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -19,7 +18,6 @@ import (
 	"github.com/mblarer/conpass/segment"
 	"github.com/netsec-ethz/scion-apps/pkg/appnet"
 	"github.com/scionproto/scion/go/lib/addr"
-	"github.com/scionproto/scion/go/lib/pathpol"
 	"github.com/scionproto/scion/go/lib/snet"
 	"github.com/scionproto/scion/go/lib/sock/reliable"
 	"github.com/scionproto/scion/go/pkg/ping"
@@ -29,27 +27,31 @@ const (
 	quicTransport bool = false
 	tlsTransport  bool = true
 
-	defaultAclFilepath     = ""
-	defaultHost            = "127.0.0.1"
-	defaultNegotiationPort = "50000"
-	defaultProfileFilepath = ""
-	defaultSeqFilepath     = ""
-	defaultShouldNegotiate = true
-	defaultTargetIA        = "17-ffaa:0:1102" // ETHZ
-	defaultTransport       = quicTransport
-	defaultVerbose         = false
+	defaultAclFilepath      = ""
+	defaultPipelineFilepath = ""
+	defaultPolicyFilepath   = ""
+	defaultHost             = "127.0.0.1"
+	defaultNegotiationPort  = "50000"
+	defaultProfileFilepath  = ""
+	defaultSeqFilepath      = ""
+	defaultShouldNegotiate  = true
+	defaultTargetIA         = "17-ffaa:0:1102" // ETHZ
+	defaultTransport        = quicTransport
+	defaultVerbose          = false
 )
 
 var (
-	aclFilepath     string
-	host            string
-	negotiationPort string
-	profileFilepath string
-	seqFilepath     string
-	shouldNegotiate bool
-	targetIA        string
-	transport       bool
-	verbose         bool
+	aclFilepath      string
+	pipelineFilepath string
+	policyFilepath   string
+	host             string
+	negotiationPort  string
+	profileFilepath  string
+	seqFilepath      string
+	shouldNegotiate  bool
+	targetIA         string
+	transport        bool
+	verbose          bool
 
 	profileFile *os.File
 	startTime   time.Time
@@ -101,6 +103,10 @@ func stopMeasurements() {
 func parseArgs() {
 	flag.StringVar(&aclFilepath, "acl", defaultAclFilepath,
 		"path to ACL definition file (JSON)")
+	flag.StringVar(&pipelineFilepath, "pipeline", defaultPipelineFilepath,
+		"path to filter pipeline configuration (JSON), overrides -policy, -acl and -seq")
+	flag.StringVar(&policyFilepath, "policy", defaultPolicyFilepath,
+		"path to path policy file (JSON), overrides -acl and -seq")
 	flag.StringVar(&host, "host", defaultHost,
 		"IP address of the negotiation server")
 	flag.StringVar(&negotiationPort, "port", defaultNegotiationPort,
@@ -145,13 +151,21 @@ func negotiate(paths []snet.Path, srcIA, dstIA addr.IA) []snet.Path {
 	if verbose {
 		log.Println("split paths into", len(segset.Segments), "different segments")
 	}
-	filter := buildFilter()
-	agent := conpass.Initiator{InitialSegset: segset, Filter: filter, Verbose: verbose}
+	segfilter, err := filter.LoadFiles(filter.Files{
+		Pipeline: pipelineFilepath,
+		Policy:   policyFilepath,
+		ACL:      aclFilepath,
+		Sequence: seqFilepath,
+	})
+	if err != nil {
+		panic(err)
+	}
+	agent := conpass.Initiator{InitialSegset: segset, Filter: segfilter, Verbose: verbose}
 
 	address := fmt.Sprintf("%s:%s", host, negotiationPort)
 	stream := dial(address)
 	defer stream.Close()
-	segset, err := agent.NegotiateOver(stream)
+	segset, err = agent.NegotiateOver(stream)
 	if err != nil {
 		panic(err)
 	}
@@ -175,22 +189,6 @@ func buildSegmentSet(paths []snet.Path, srcIA, dstIA addr.IA) segment.SegmentSet
 	}
 }
 
-func buildFilter() segment.Filter {
-	acl := createACL()
-	seq := createSequence()
-	filters := make([]segment.Filter, 0)
-	if acl != nil {
-		aclFilter := filter.FromACL(*acl)
-		filters = append(filters, aclFilter)
-	}
-	if seq != nil {
-		pathEnumerator := filter.SrcDstPathEnumerator()
-		sequenceFilter := filter.FromSequence(*seq)
-		filters = append(filters, pathEnumerator, sequenceFilter)
-	}
-	return filter.FromFilters(filters...)
-}
-
 func dial(address string) io.ReadWriteCloser {
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: true,
@@ -236,35 +234,3 @@ func pingOnPath(path snet.Path, srcIA, dstIA addr.IA) {
 		Timeout:    150 * time.Millisecond,
 	})
 }
-
-func createACL() *pathpol.ACL {
-	if aclFilepath == "" {
-		return nil
-	}
-	acl := new(pathpol.ACL)
-	jsonACL, err := os.ReadFile(aclFilepath)
-	if err != nil {
-		panic(err)
-	}
-	err = json.Unmarshal(jsonACL, &acl)
-	if err != nil {
-		panic(err)
-	}
-	return acl
-}
-
-func createSequence() *pathpol.Sequence {
-	if seqFilepath == "" {
-		return nil
-	}
-	seq := new(pathpol.Sequence)
-	jsonSeq, err := os.ReadFile(seqFilepath)
-	if err != nil {
-		panic(err)
-	}
-	err = json.Unmarshal(jsonSeq, &seq)
-	if err != nil {
-		panic(err)
-	}
-	return seq
-}
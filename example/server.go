@@ -6,7 +6,6 @@ import (
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
 	"encoding/pem"
 	"flag"
 	"fmt"
@@ -15,34 +14,44 @@ import (
 	"math/big"
 	"net"
 	"os"
+	"time"
 
 	"github.com/lucas-clemente/quic-go"
 	"github.com/mblarer/conpass"
 	"github.com/mblarer/conpass/filter"
 	"github.com/mblarer/conpass/segment"
-	"github.com/scionproto/scion/go/lib/pathpol"
 )
 
 const (
 	quicTransport bool = false
 	tlsTransport  bool = true
 
-	defaultAclFilepath     = ""
-	defaultSeqFilepath     = ""
-	defaultHost            = "127.0.0.1"
-	defaultNegotiationPort = "50000"
-	defaultTransport       = quicTransport
-	defaultVerbose         = false
+	defaultAclFilepath      = ""
+	defaultClientCAFilepath = ""
+	defaultPipelineFilepath = ""
+	defaultPolicyFilepath   = ""
+	defaultRulesFilepath    = ""
+	defaultSeqFilepath      = ""
+	defaultHost             = "127.0.0.1"
+	defaultNegotiationPort  = "50000"
+	defaultTransport        = quicTransport
+	defaultVerbose          = false
+	defaultWatchInterval    = 0
 )
 
 var (
-	aclFilepath     string
-	seqFilepath     string
-	targetIA        string
-	host            string
-	negotiationPort string
-	transport       bool
-	verbose         bool
+	aclFilepath      string
+	clientCAFilepath string
+	pipelineFilepath string
+	policyFilepath   string
+	rulesFilepath    string
+	seqFilepath      string
+	targetIA         string
+	host             string
+	negotiationPort  string
+	transport        bool
+	verbose          bool
+	watchInterval    time.Duration
 )
 
 func main() {
@@ -58,6 +67,14 @@ func main() {
 func parseArgs() {
 	flag.StringVar(&aclFilepath, "acl", defaultAclFilepath,
 		"path to ACL definition file (JSON)")
+	flag.StringVar(&clientCAFilepath, "clientca", defaultClientCAFilepath,
+		"path to CA certificates (PEM) that client certificates are verified against, see -rules")
+	flag.StringVar(&pipelineFilepath, "pipeline", defaultPipelineFilepath,
+		"path to filter pipeline configuration (JSON), overrides -policy, -acl and -seq")
+	flag.StringVar(&policyFilepath, "policy", defaultPolicyFilepath,
+		"path to path policy file (JSON), overrides -acl and -seq")
+	flag.StringVar(&rulesFilepath, "rules", defaultRulesFilepath,
+		"path to per-client rule table (JSON), overrides -policy, -acl and -seq")
 	flag.StringVar(&seqFilepath, "seq", defaultSeqFilepath,
 		"path to sequence definition file (JSON)")
 	flag.StringVar(&host, "host", defaultHost,
@@ -68,6 +85,8 @@ func parseArgs() {
 		"use TLS instead of default QUIC")
 	flag.BoolVar(&verbose, "v", defaultVerbose,
 		"be verbose and log to stdout")
+	flag.DurationVar(&watchInterval, "watch", defaultWatchInterval,
+		"interval at which filter files are checked for changes (default: off, reload on SIGHUP only)")
 	flag.Parse()
 }
 
@@ -139,60 +158,32 @@ func runNegotiationServer() {
 	if verbose {
 		log.Printf("server listening at %s", address)
 	}
-	filter := buildFilter()
-	agent := conpass.Responder{Filter: filter, Verbose: verbose}
-	for {
-		stream := listener.accept()
-		go agent.NegotiateOver(stream)
-	}
-}
-
-func buildFilter() segment.Filter {
-	acl := createACL()
-	seq := createSequence()
-	filters := make([]segment.Filter, 0)
-	if acl != nil {
-		aclFilter := filter.FromACL(*acl)
-		filters = append(filters, aclFilter)
-	}
-	if seq != nil {
-		pathEnumerator := filter.SrcDstPathEnumerator()
-		sequenceFilter := filter.FromSequence(*seq)
-		filters = append(filters, pathEnumerator, sequenceFilter)
-	}
-	return filter.FromFilters(filters...)
-}
-
-func createACL() *pathpol.ACL {
-	if aclFilepath == "" {
-		return nil
-	}
-	acl := new(pathpol.ACL)
-	jsonACL, err := os.ReadFile(aclFilepath)
+	reloader, err := filter.NewReloader(func() (segment.Filter, error) {
+		return filter.LoadFiles(filterFiles())
+	})
 	if err != nil {
 		panic(err)
 	}
-	err = json.Unmarshal(jsonACL, &acl)
-	if err != nil {
-		panic(err)
+	go reloader.ReloadOnSignal(context.Background())
+	if watchInterval > 0 {
+		go reloader.WatchFiles(context.Background(), watchInterval, filterFiles().Paths()...)
+	}
+	agent := conpass.Responder{Filter: reloader, Verbose: verbose}
+	for {
+		stream := listener.accept()
+		go agent.NegotiateOver(stream)
 	}
-	return acl
 }
 
-func createSequence() *pathpol.Sequence {
-	if seqFilepath == "" {
-		return nil
-	}
-	seq := new(pathpol.Sequence)
-	jsonSeq, err := os.ReadFile(seqFilepath)
-	if err != nil {
-		panic(err)
-	}
-	err = json.Unmarshal(jsonSeq, &seq)
-	if err != nil {
-		panic(err)
+// filterFiles returns the files from which the filter is loaded.
+func filterFiles() filter.Files {
+	return filter.Files{
+		Rules:    rulesFilepath,
+		Pipeline: pipelineFilepath,
+		Policy:   policyFilepath,
+		ACL:      aclFilepath,
+		Sequence: seqFilepath,
 	}
-	return seq
 }
 
 func generateTLSConfig() *tls.Config {
@@ -222,5 +213,22 @@ func generateTLSConfig() *tls.Config {
 	return &tls.Config{
 		Certificates: []tls.Certificate{tlsCert},
 		NextProtos:   []string{"conpass-example"},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    createClientCAs(),
+	}
+}
+
+func createClientCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	if clientCAFilepath == "" {
+		return pool
+	}
+	pemCerts, err := os.ReadFile(clientCAFilepath)
+	if err != nil {
+		panic(err)
+	}
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		panic("no CA certificates found in " + clientCAFilepath)
 	}
+	return pool
 }
@@ -1,6 +1,8 @@
 package filter
 
 import (
+	"fmt"
+
 	"github.com/mblarer/conpass/path"
 	"github.com/mblarer/conpass/segment"
 	"github.com/scionproto/scion/go/lib/pathpol"
@@ -18,12 +20,105 @@ type aclFilter struct {
 }
 
 func (af aclFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	decisions := make(map[string]bool)
 	return FromPredicate(func(segment segment.Segment) bool {
-		// This implementation is not optimal. If the segment is a segment
-		// composition, then every subsegment should be evaluated only once.
-		path := path.InterfacePath{segment.PathInterfaces()}
-		result := af.acl.Eval([]snet.Path{path})
-		accept := len(result) == 1
+		accept := af.accept(segment, decisions)
+		if !accept && segset.Trace != nil {
+			segset.Trace.Explain(segment, af.explain(segment))
+		}
 		return accept
 	}).Filter(segset)
 }
+
+func (af aclFilter) String() string {
+	return "acl"
+}
+
+// accept evaluates the ACL on a segment and memoizes the decision by the
+// segment's fingerprint. The ACL is evaluated for every interface on its own,
+// where interfaces at odd positions are ingress interfaces. Since literals
+// consist of an even number of interfaces, a composition of such literals is
+// accepted if and only if all of its subsegments are accepted. Therefore,
+// every subsegment is evaluated only once, even if it is part of many
+// compositions. Other segments are evaluated as a whole.
+func (af aclFilter) accept(seg segment.Segment, decisions map[string]bool) bool {
+	fprint := seg.Fingerprint()
+	if accept, ok := decisions[fprint]; ok {
+		return accept
+	}
+	accept := true
+	if composition, ok := seg.(segment.Composition); ok && evenLength(composition) {
+		for _, subseg := range composition.Segments {
+			if !af.accept(subseg, decisions) {
+				accept = false
+				break
+			}
+		}
+	} else {
+		accept = af.eval(seg)
+	}
+	decisions[fprint] = accept
+	return accept
+}
+
+func (af aclFilter) eval(segment segment.Segment) bool {
+	path := path.InterfacePath{Interfaces: segment.PathInterfaces()}
+	result := af.acl.Eval([]snet.Path{path})
+	return len(result) == 1
+}
+
+// explain returns the first interface of a segment that is denied by the ACL,
+// together with the ACL entry that matched it.
+func (af aclFilter) explain(segment segment.Segment) string {
+	for i, iface := range segment.PathInterfaces() {
+		for _, entry := range af.acl.Entries {
+			if entry.Rule != nil && !matchesInterface(entry.Rule, iface, i%2 != 0) {
+				continue
+			}
+			if entry.Action == pathpol.Deny {
+				return fmt.Sprintf("%s#%d matches %q", iface.IA, iface.ID, entry)
+			}
+			break
+		}
+	}
+	return ""
+}
+
+// matchesInterface reports whether a hop predicate of an ACL entry matches an
+// interface, like pathpol.ACL does internally.
+func matchesInterface(rule *pathpol.HopPredicate, iface snet.PathInterface, ingress bool) bool {
+	if rule.ISD != 0 && iface.IA.I != rule.ISD {
+		return false
+	}
+	if rule.AS != 0 && iface.IA.A != rule.AS {
+		return false
+	}
+	ifid := rule.IfIDs[0]
+	if len(rule.IfIDs) == 2 && !ingress {
+		ifid = rule.IfIDs[1]
+	}
+	return ifid == 0 || ifid == iface.ID
+}
+
+// evenLength reports whether all subsegments of a composition consist of an
+// even number of interfaces, such that an interface has the same position
+// parity in its subsegment as in the composition.
+func evenLength(composition segment.Composition) bool {
+	for _, subseg := range composition.Segments {
+		switch s := subseg.(type) {
+		case segment.Literal:
+			if len(s.Interfaces)%2 != 0 {
+				return false
+			}
+		case segment.Composition:
+			if !evenLength(s) {
+				return false
+			}
+		default:
+			if len(s.PathInterfaces())%2 != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
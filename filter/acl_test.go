@@ -0,0 +1,79 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/mblarer/conpass/internal"
+	"github.com/mblarer/conpass/path"
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/pathpol"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+func TestACLMatchesUncachedEvaluation(t *testing.T) {
+	segset := enumeratedSegmentSet(5, 4)
+	for _, str := range []string{`["+"]`, `["-"]`, `["- 1-ffaa:0:2", "+"]`, `["- 2-ffaa:0:1#2", "+ 1", "-"]`} {
+		acl := mustACL(str)
+		want := uncachedACL(acl).Filter(segset).Segments
+		have := FromACL(acl).Filter(segset).Segments
+		if len(have) != len(want) {
+			t.Errorf("%s: want %d segments, have %d", str, len(want), len(have))
+			continue
+		}
+		for i := range have {
+			if have[i].Fingerprint() != want[i].Fingerprint() {
+				t.Errorf("%s: want: %s, have: %s", str, want[i], have[i])
+			}
+		}
+	}
+}
+
+func BenchmarkACL(b *testing.B) {
+	segset := enumeratedSegmentSet(15, 6)
+	acl := FromACL(mustACL(`["- 1-ffaa:0:3", "- 2-ffaa:0:4", "+"]`))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		acl.Filter(segset)
+	}
+}
+
+func BenchmarkACLUncached(b *testing.B) {
+	segset := enumeratedSegmentSet(15, 6)
+	acl := uncachedACL(mustACL(`["- 1-ffaa:0:3", "- 2-ffaa:0:4", "+"]`))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		acl.Filter(segset)
+	}
+}
+
+// enumeratedSegmentSet returns all paths from the benchmark topology with k
+// segments per hop, like the client-side enumeration in the benchmarks.
+func enumeratedSegmentSet(k, hops int) segment.SegmentSet {
+	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
+	core1, _ := addr.IAFromString("1-ffaa:0:1000")
+	core2, _ := addr.IAFromString("2-ffaa:0:1")
+	dstIA, _ := addr.IAFromString("2-ffaa:0:1000")
+	segments := make([]segment.Segment, 0)
+	segments = append(segments, internal.GenerateSegments(k, hops, srcIA, core1)...)
+	segments = append(segments, internal.GenerateSegments(k, hops, core1, core2)...)
+	segments = append(segments, internal.GenerateSegments(k, hops, core2, dstIA)...)
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	return SrcDstPathEnumerator().Filter(segset)
+}
+
+// uncachedACL evaluates the ACL on every segment as a whole.
+func uncachedACL(acl pathpol.ACL) segment.Filter {
+	return FromPredicate(func(segment segment.Segment) bool {
+		path := path.InterfacePath{Interfaces: segment.PathInterfaces()}
+		return len(acl.Eval([]snet.Path{path})) == 1
+	})
+}
+
+func mustACL(str string) pathpol.ACL {
+	acl := new(pathpol.ACL)
+	if err := acl.UnmarshalJSON([]byte(str)); err != nil {
+		panic(err)
+	}
+	return *acl
+}
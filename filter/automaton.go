@@ -0,0 +1,325 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/common"
+)
+
+// sequenceAutomaton is a nondeterministic finite automaton that is compiled
+// from the string representation of a pathpol.Sequence. It implements the
+// segment.HopAutomaton interface. A state of the automaton is the set of
+// active NFA states, encoded as a string.
+type sequenceAutomaton struct {
+	states []nfaState
+	start  int
+	accept int
+}
+
+type nfaState struct {
+	epsilon   []int
+	predicate *hopPredicate
+	next      int
+}
+
+// hopPredicate is a hop predicate of a pathpol.Sequence. An ISD or AS of 0
+// and an interface ID of 0 are wildcards. A single interface ID matches
+// either the ingress or the egress interface of a hop, two interface IDs
+// match the ingress and the egress interface, respectively.
+type hopPredicate struct {
+	isd   addr.ISD
+	as    addr.AS
+	ifids []common.IFIDType
+}
+
+func (hp *hopPredicate) match(hop segment.Hop) bool {
+	if hp.isd != 0 && hop.IA.I != hp.isd {
+		return false
+	}
+	if hp.as != 0 && hop.IA.A != hp.as {
+		return false
+	}
+	switch len(hp.ifids) {
+	case 1:
+		return hp.ifids[0] == 0 || hp.ifids[0] == hop.Ingress || hp.ifids[0] == hop.Egress
+	case 2:
+		return (hp.ifids[0] == 0 || hp.ifids[0] == hop.Ingress) &&
+			(hp.ifids[1] == 0 || hp.ifids[1] == hop.Egress)
+	}
+	return true
+}
+
+// compileSequence compiles the string representation of a pathpol.Sequence
+// into an automaton. The syntax and operator precedence are the same as in
+// pathpol: postfix operators (?, +, *) bind strongest, followed by
+// alternation (|) and concatenation.
+func compileSequence(sequence string) (*sequenceAutomaton, error) {
+	p := &sequenceParser{tokens: tokenizeSequence(sequence)}
+	sa := &sequenceAutomaton{}
+	p.automaton = sa
+	frag, err := p.parseConcatenation()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q in sequence %q", p.tokens[p.pos], sequence)
+	}
+	sa.start, sa.accept = frag.start, frag.end
+	return sa, nil
+}
+
+func (sa *sequenceAutomaton) Start() string {
+	return encodeStates(sa.closure([]int{sa.start}))
+}
+
+func (sa *sequenceAutomaton) Step(state string, hop segment.Hop) (string, bool) {
+	next := make([]int, 0)
+	for _, s := range decodeStates(state) {
+		if p := sa.states[s].predicate; p != nil && p.match(hop) {
+			next = append(next, sa.states[s].next)
+		}
+	}
+	if len(next) == 0 {
+		return "", false
+	}
+	return encodeStates(sa.closure(next)), true
+}
+
+func (sa *sequenceAutomaton) Accepts(state string) bool {
+	for _, s := range decodeStates(state) {
+		if s == sa.accept {
+			return true
+		}
+	}
+	return false
+}
+
+func (sa *sequenceAutomaton) closure(states []int) []int {
+	seen := make(map[int]bool)
+	stack := append([]int(nil), states...)
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		stack = append(stack, sa.states[s].epsilon...)
+	}
+	closure := make([]int, 0, len(seen))
+	for s := range seen {
+		closure = append(closure, s)
+	}
+	sort.Ints(closure)
+	return closure
+}
+
+func (sa *sequenceAutomaton) newState() int {
+	sa.states = append(sa.states, nfaState{next: -1})
+	return len(sa.states) - 1
+}
+
+func (sa *sequenceAutomaton) addEpsilon(from int, to ...int) {
+	sa.states[from].epsilon = append(sa.states[from].epsilon, to...)
+}
+
+func encodeStates(states []int) string {
+	strs := make([]string, len(states))
+	for i, s := range states {
+		strs[i] = strconv.Itoa(s)
+	}
+	return strings.Join(strs, ",")
+}
+
+func decodeStates(state string) []int {
+	if state == "" {
+		return nil
+	}
+	strs := strings.Split(state, ",")
+	states := make([]int, len(strs))
+	for i, str := range strs {
+		states[i], _ = strconv.Atoi(str)
+	}
+	return states
+}
+
+// fragment is a part of the automaton with a single start and end state.
+type fragment struct {
+	start, end int
+}
+
+type sequenceParser struct {
+	tokens    []string
+	pos       int
+	automaton *sequenceAutomaton
+}
+
+func tokenizeSequence(sequence string) []string {
+	tokens := make([]string, 0)
+	var hop strings.Builder
+	flush := func() {
+		if hop.Len() > 0 {
+			tokens = append(tokens, hop.String())
+			hop.Reset()
+		}
+	}
+	for _, r := range sequence {
+		switch {
+		case strings.ContainsRune("()?+*|", r):
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			flush()
+		default:
+			hop.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func (p *sequenceParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// parseConcatenation parses a non-empty sequence of alternations.
+func (p *sequenceParser) parseConcatenation() (fragment, error) {
+	frag, err := p.parseAlternation()
+	if err != nil {
+		return frag, err
+	}
+	for tok := p.peek(); tok != "" && tok != ")"; tok = p.peek() {
+		next, err := p.parseAlternation()
+		if err != nil {
+			return frag, err
+		}
+		p.automaton.addEpsilon(frag.end, next.start)
+		frag.end = next.end
+	}
+	return frag, nil
+}
+
+// parseAlternation parses postfix expressions separated by |.
+func (p *sequenceParser) parseAlternation() (fragment, error) {
+	frag, err := p.parsePostfix()
+	if err != nil {
+		return frag, err
+	}
+	for p.peek() == "|" {
+		p.pos++
+		right, err := p.parsePostfix()
+		if err != nil {
+			return frag, err
+		}
+		sa := p.automaton
+		start, end := sa.newState(), sa.newState()
+		sa.addEpsilon(start, frag.start, right.start)
+		sa.addEpsilon(frag.end, end)
+		sa.addEpsilon(right.end, end)
+		frag = fragment{start: start, end: end}
+	}
+	return frag, nil
+}
+
+// parsePostfix parses an atom followed by any number of ?, + or *.
+func (p *sequenceParser) parsePostfix() (fragment, error) {
+	frag, err := p.parseAtom()
+	if err != nil {
+		return frag, err
+	}
+	sa := p.automaton
+	for {
+		switch p.peek() {
+		case "?":
+			start, end := sa.newState(), sa.newState()
+			sa.addEpsilon(start, frag.start, end)
+			sa.addEpsilon(frag.end, end)
+			frag = fragment{start: start, end: end}
+		case "+":
+			end := sa.newState()
+			sa.addEpsilon(frag.end, frag.start, end)
+			frag = fragment{start: frag.start, end: end}
+		case "*":
+			start, end := sa.newState(), sa.newState()
+			sa.addEpsilon(start, frag.start, end)
+			sa.addEpsilon(frag.end, frag.start, end)
+			frag = fragment{start: start, end: end}
+		default:
+			return frag, nil
+		}
+		p.pos++
+	}
+}
+
+// parseAtom parses a parenthesized sequence or a hop predicate.
+func (p *sequenceParser) parseAtom() (fragment, error) {
+	tok := p.peek()
+	switch tok {
+	case "":
+		return fragment{}, fmt.Errorf("unexpected end of sequence")
+	case "(":
+		p.pos++
+		frag, err := p.parseConcatenation()
+		if err != nil {
+			return frag, err
+		}
+		if p.peek() != ")" {
+			return frag, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return frag, nil
+	case ")", "?", "+", "*", "|":
+		return fragment{}, fmt.Errorf("unexpected token %q", tok)
+	}
+	p.pos++
+	predicate, err := parseHopPredicate(tok)
+	if err != nil {
+		return fragment{}, err
+	}
+	sa := p.automaton
+	start, end := sa.newState(), sa.newState()
+	sa.states[start].predicate = predicate
+	sa.states[start].next = end
+	return fragment{start: start, end: end}, nil
+}
+
+// parseHopPredicate parses a hop predicate of the form ISD, ISD-AS,
+// ISD-AS#IF or ISD-AS#IF,IF.
+func parseHopPredicate(str string) (*hopPredicate, error) {
+	dashParts := strings.SplitN(str, "-", 2)
+	isd, err := addr.ISDFromString(dashParts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ISD in hop predicate %q: %s", str, err)
+	}
+	predicate := &hopPredicate{isd: isd}
+	if len(dashParts) == 1 {
+		return predicate, nil
+	}
+	hashParts := strings.SplitN(dashParts[1], "#", 2)
+	if predicate.as, err = addr.ASFromString(hashParts[0]); err != nil {
+		return nil, fmt.Errorf("invalid AS in hop predicate %q: %s", str, err)
+	}
+	if len(hashParts) == 1 {
+		return predicate, nil
+	}
+	commaParts := strings.Split(hashParts[1], ",")
+	if len(commaParts) > 2 {
+		return nil, fmt.Errorf("too many interfaces in hop predicate %q", str)
+	}
+	for _, part := range commaParts {
+		ifid, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interface in hop predicate %q: %s", str, err)
+		}
+		predicate.ifids = append(predicate.ifids, common.IFIDType(ifid))
+	}
+	return predicate, nil
+}
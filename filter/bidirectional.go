@@ -0,0 +1,33 @@
+package filter
+
+import (
+	"context"
+
+	"github.com/mblarer/conpass/segment"
+)
+
+// Bidirectional returns a segment.Filter that only accepts segments that the
+// given filter accepts in both directions. The filter is applied once to the
+// original SegmentSet and once to the reversed SegmentSet, i.e., from the
+// perspective of the destination. The result contains the segments of the
+// first application that are also contained in the reversed result of the
+// second application.
+func Bidirectional(filter segment.Filter) segment.Filter {
+	return bidirectionalFilter{filter: filter}
+}
+
+type bidirectionalFilter struct {
+	filter segment.Filter
+}
+
+func (bf bidirectionalFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return bf.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (bf bidirectionalFilter) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	forward := segment.ApplyFilter(ctx, info, bf.filter, segset)
+	reversed := segset.Reverse()
+	reversed.Trace = nil // only the forward decisions are traced
+	backward := segment.ApplyFilter(ctx, info, bf.filter, reversed).Reverse()
+	return forward.Intersect(backward, segment.InterfaceFingerprint)
+}
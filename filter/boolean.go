@@ -0,0 +1,126 @@
+package filter
+
+import (
+	"context"
+
+	"github.com/mblarer/conpass/segment"
+)
+
+// The combinators in this file compare segments by their path interfaces, see
+// segment.InterfaceFingerprint. Any, All and Not treat the given filters as
+// predicates on the input segments: their result is always a subset of the
+// input, and segments that a filter adds, e.g., paths that are enumerated from
+// the input, are discarded. Union and Intersect operate on the results of the
+// given filters instead and do keep such segments.
+
+// Any returns a segment.Filter that accepts the input segments that at least
+// one of the given filters accepts. Without filters, no segment is accepted.
+func Any(filters ...segment.Filter) segment.Filter {
+	return anyFilter{filters: filters}
+}
+
+type anyFilter struct {
+	filters []segment.Filter
+}
+
+func (af anyFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return af.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (af anyFilter) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	union := unionFilter(af).FilterWithContext(ctx, info, segset)
+	return segset.Intersect(union, segment.InterfaceFingerprint)
+}
+
+// All returns a segment.Filter that accepts the input segments that all of the
+// given filters accept. Without filters, all segments are accepted. Unlike
+// FromFilters, every filter is applied to the original input.
+func All(filters ...segment.Filter) segment.Filter {
+	return allFilter{filters: filters}
+}
+
+type allFilter struct {
+	filters []segment.Filter
+}
+
+func (af allFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return af.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (af allFilter) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	for _, filter := range af.filters {
+		result := segment.ApplyFilter(ctx, info, filter, segset)
+		segset = segset.Intersect(result, segment.InterfaceFingerprint)
+	}
+	return segset
+}
+
+// Not returns a segment.Filter that accepts the input segments that the given
+// filter does not accept.
+func Not(filter segment.Filter) segment.Filter {
+	return notFilter{filter: filter}
+}
+
+type notFilter struct {
+	filter segment.Filter
+}
+
+func (nf notFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return nf.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (nf notFilter) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	result := segment.ApplyFilter(ctx, info, nf.filter, segset)
+	return segset.Difference(result, segment.InterfaceFingerprint)
+}
+
+// Union returns a segment.Filter that applies each of the given filters to the
+// input and returns the union of their results, in the order of the filters.
+// Without filters, the result is empty.
+func Union(filters ...segment.Filter) segment.Filter {
+	return unionFilter{filters: filters}
+}
+
+type unionFilter struct {
+	filters []segment.Filter
+}
+
+func (uf unionFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return uf.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (uf unionFilter) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	union := segset.WithSegments(nil)
+	for _, filter := range uf.filters {
+		result := segment.ApplyFilter(ctx, info, filter, segset)
+		union = union.Union(result, segment.InterfaceFingerprint)
+	}
+	return union
+}
+
+// Intersect returns a segment.Filter that applies each of the given filters to
+// the input and returns the segments of the first result that are contained in
+// all other results. Without filters, the input is returned unchanged.
+func Intersect(filters ...segment.Filter) segment.Filter {
+	return intersectFilter{filters: filters}
+}
+
+type intersectFilter struct {
+	filters []segment.Filter
+}
+
+func (inf intersectFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return inf.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (inf intersectFilter) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	if len(inf.filters) == 0 {
+		return segset
+	}
+	intersection := segment.ApplyFilter(ctx, info, inf.filters[0], segset)
+	for _, filter := range inf.filters[1:] {
+		result := segment.ApplyFilter(ctx, info, filter, segset)
+		intersection = intersection.Intersect(result, segment.InterfaceFingerprint)
+	}
+	return intersection
+}
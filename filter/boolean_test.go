@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+func TestBooleanCombinators(t *testing.T) {
+	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
+	dstIA, _ := addr.IAFromString("1-ffaa:0:3")
+	s1 := segment.FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")
+	s2 := segment.FromString("1-ffaa:0:1 2>2 1-ffaa:0:2")
+	s3 := segment.FromString("1-ffaa:0:2 3>1 1-ffaa:0:3")
+	segset := segment.SegmentSet{
+		Segments: []segment.Segment{s1, s2, s3},
+		SrcIA:    srcIA,
+		DstIA:    dstIA,
+	}
+	only := func(segments ...segment.Segment) segment.Filter {
+		return FromPredicate(func(seg segment.Segment) bool {
+			for _, s := range segments {
+				if s.Fingerprint() == seg.Fingerprint() {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	enumerator := SrcDstPathEnumerator()
+
+	tests := []struct {
+		name   string
+		filter segment.Filter
+		want   []segment.Segment
+	}{
+		{"any", Any(only(s1), only(s3)), []segment.Segment{s1, s3}},
+		{"any without filters", Any(), []segment.Segment{}},
+		{"any with enumerator", Any(enumerator, only(s2)), []segment.Segment{s2}},
+		{"all", All(only(s1, s2), only(s2, s3)), []segment.Segment{s2}},
+		{"all without filters", All(), []segment.Segment{s1, s2, s3}},
+		{"not", Not(only(s1)), []segment.Segment{s2, s3}},
+		{"not enumerator", Not(enumerator), []segment.Segment{s1, s2, s3}},
+		{"union", Union(only(s3), only(s1, s3)), []segment.Segment{s3, s1}},
+		{"union with enumerator", Union(only(s3), enumerator), []segment.Segment{
+			s3, segment.FromSegments(s1, s3), segment.FromSegments(s2, s3),
+		}},
+		{"intersect", Intersect(only(s1, s2), only(s2, s3)), []segment.Segment{s2}},
+		{"intersect with enumerator", Intersect(enumerator, FromFilters(Not(only(s1)), enumerator)),
+			[]segment.Segment{segment.FromSegments(s2, s3)}},
+	}
+	for _, test := range tests {
+		have := test.filter.Filter(segset).Segments
+		if len(have) != len(test.want) {
+			t.Errorf("%s: want %d segments, have %d", test.name, len(test.want), len(have))
+			continue
+		}
+		for i := range have {
+			if segment.InterfaceFingerprint(have[i]) != segment.InterfaceFingerprint(test.want[i]) {
+				t.Errorf("%s: want: %s, have: %s", test.name, test.want[i], have[i])
+			}
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package filter
 
-import "github.com/mblarer/conpass/segment"
+import (
+	"context"
+
+	"github.com/mblarer/conpass/segment"
+)
 
 // FromFilters returns a segment.Filter that applies a sequence of
-// caller-supplied filters, in the given order.
+// caller-supplied filters, in the given order. If the SegmentSet carries a
+// segment.Trace, the decisions of every filter are recorded as a stage.
 func FromFilters(filters ...segment.Filter) segment.Filter {
 	return filterComposition{filters: filters}
 }
@@ -13,8 +18,12 @@ type filterComposition struct {
 }
 
 func (fc filterComposition) Filter(segset segment.SegmentSet) segment.SegmentSet {
-	for _, filter := range fc.filters {
-		segset = filter.Filter(segset)
+	return fc.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (fc filterComposition) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	for i, filter := range fc.filters {
+		segset = traced(ctx, info, filter, i, segset)
 	}
 	return segset
 }
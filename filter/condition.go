@@ -0,0 +1,35 @@
+package filter
+
+import "github.com/mblarer/conpass/segment"
+
+// Condition returns a segment.Filter that keeps all segments and attaches the
+// segment.Conditions returned by the given function to them, such that the
+// consent to the segments is conditional, see
+// segment.SegmentSet.Conditions. Conditions that were attached before are
+// combined with the new ones.
+func Condition(conditions func(segment.Segment) segment.Conditions) segment.Filter {
+	return conditionFilter{conditions: conditions}
+}
+
+type conditionFilter struct {
+	conditions func(segment.Segment) segment.Conditions
+}
+
+func (cf conditionFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	conditions := make(map[string]segment.Conditions, len(segset.Conditions)+len(segset.Segments))
+	for key, c := range segset.Conditions {
+		conditions[key] = c
+	}
+	for _, seg := range segset.Segments {
+		key := segment.InterfaceFingerprint(seg)
+		if combined := conditions[key].Combine(cf.conditions(seg)); !combined.IsZero() {
+			conditions[key] = combined
+		}
+	}
+	segset.Conditions = conditions
+	return segset
+}
+
+func (cf conditionFilter) String() string {
+	return "condition"
+}
@@ -0,0 +1,491 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/pathpol"
+)
+
+// Load reads a filter pipeline from a JSON configuration file and compiles it
+// into a segment.Filter. The configuration consists of an optional name and a
+// pipeline, i.e., a list of stages that are applied in the given order:
+//
+//	{
+//	  "name": "client",
+//	  "pipeline": [
+//	    {"acl": ["- 17-ffaa:0:1101", "+"]},
+//	    {"enumerate": {"max_segments": 3, "loop_free": true, "sequence": "19* 17*"}},
+//	    {"any": [
+//	      [{"sequence": "0* 17-ffaa:0:1102 0*"}],
+//	      [{"threshold": {"metric": "hops", "max": 8}}]
+//	    ]},
+//	    {"topk": {"k": 5, "metric": "hops"}}
+//	  ]
+//	}
+//
+// Every stage is an object with a single key:
+//
+//	acl        pathpol ACL, see FromACL
+//	sequence   pathpol sequence, see FromSequence
+//	enumerate  enumeration options and an optional sequence, see
+//	           SrcDstPathEnumeratorWithOptions and SequenceEnumeratorWithOptions
+//	topk       number of paths k and a metric, see TopK
+//	threshold  maximum cost max and a metric, see Threshold
+//	policy     path to a pathpol policy file, see LoadPolicy and FromPolicy
+//	pipeline   path to another pipeline configuration file
+//	any, all, union, intersect
+//	           list of pipelines, see Any, All, Union and Intersect
+//	not, bidirectional
+//	           pipeline, see Not and Bidirectional
+//
+// The metrics are "hops" and "segments", see segment.HopCount and
+// segment.SegmentCount. Relative file paths are resolved from the directory
+// of the configuration file. Errors refer to the line of the offending value.
+func Load(filename string) (segment.Filter, error) {
+	return loadConfig(filename, map[string]bool{})
+}
+
+func loadConfig(filename string, loading map[string]bool) (segment.Filter, error) {
+	if loading[filename] {
+		return nil, fmt.Errorf("%s: pipeline includes itself", filename)
+	}
+	loading[filename] = true
+	defer delete(loading, filename)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s", filename, err)
+	}
+	compiler := configCompiler{data: data, dir: filepath.Dir(filename), loading: loading}
+	filter, err := compiler.compileRoot(root)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s", filename, err)
+	}
+	return filter, nil
+}
+
+// configNode is a JSON value together with its position in the document.
+// The value is nil, a bool, a json.Number, a string, a []*configNode or a
+// *configObject.
+type configNode struct {
+	start, end int
+	value      interface{}
+}
+
+type configObject struct {
+	keys   []string
+	values map[string]*configNode
+}
+
+// configError is an error that refers to a position in the document.
+type configError struct {
+	offset int
+	msg    string
+}
+
+func (e *configError) Error() string {
+	return e.msg
+}
+
+// parseConfig parses a JSON document into a tree of configNodes. Errors are
+// prefixed with the line number.
+func parseConfig(data []byte) (*configNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := parseConfigNode(dec, data)
+	if err == nil {
+		if _, err = dec.Token(); err == nil {
+			err = &configError{offset: int(dec.InputOffset()), msg: "unexpected data after top-level value"}
+		} else if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, positioned(data, err)
+	}
+	return root, nil
+}
+
+func parseConfigNode(dec *json.Decoder, data []byte) (*configNode, error) {
+	node := &configNode{start: skipSeparators(data, int(dec.InputOffset()))}
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch delim := token.(type) {
+	case json.Delim:
+		switch delim {
+		case '[':
+			elems := make([]*configNode, 0)
+			for dec.More() {
+				elem, err := parseConfigNode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				elems = append(elems, elem)
+			}
+			node.value = elems
+		case '{':
+			object := &configObject{values: make(map[string]*configNode)}
+			for dec.More() {
+				keyOffset := skipSeparators(data, int(dec.InputOffset()))
+				token, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key := token.(string)
+				if _, ok := object.values[key]; ok {
+					return nil, &configError{offset: keyOffset, msg: fmt.Sprintf("duplicate key %q", key)}
+				}
+				value, err := parseConfigNode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				object.keys = append(object.keys, key)
+				object.values[key] = value
+			}
+			node.value = object
+		}
+		if _, err := dec.Token(); err != nil { // closing delimiter
+			return nil, err
+		}
+	default:
+		node.value = token
+	}
+	node.end = int(dec.InputOffset())
+	return node, nil
+}
+
+// skipSeparators returns the offset of the next value after the given offset.
+func skipSeparators(data []byte, offset int) int {
+	for offset < len(data) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// positioned prefixes an error with the line at which it occurred.
+func positioned(data []byte, err error) error {
+	offset := len(data)
+	var syntaxErr *json.SyntaxError
+	var cfgErr *configError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = int(syntaxErr.Offset)
+	case errors.As(err, &cfgErr):
+		offset = cfgErr.offset
+	}
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	return fmt.Errorf("%d: %s", line, err)
+}
+
+// configCompiler compiles a tree of configNodes into a segment.Filter.
+type configCompiler struct {
+	data    []byte
+	dir     string
+	loading map[string]bool
+}
+
+func (c configCompiler) errorf(node *configNode, format string, args ...interface{}) error {
+	return positioned(c.data, &configError{offset: node.start, msg: fmt.Sprintf(format, args...)})
+}
+
+func (c configCompiler) compileRoot(node *configNode) (segment.Filter, error) {
+	object, ok := node.value.(*configObject)
+	if !ok {
+		return nil, c.errorf(node, "configuration must be an object")
+	}
+	name := ""
+	var pipeline segment.Filter
+	for _, key := range object.keys {
+		value := object.values[key]
+		switch key {
+		case "name":
+			str, ok := value.value.(string)
+			if !ok {
+				return nil, c.errorf(value, "name must be a string")
+			}
+			name = str
+		case "pipeline":
+			var err error
+			if pipeline, err = c.compilePipeline(value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, c.errorf(value, "unknown key %q", key)
+		}
+	}
+	if pipeline == nil {
+		return nil, c.errorf(node, "pipeline is missing")
+	}
+	if name != "" {
+		return Stage(name, pipeline), nil
+	}
+	return pipeline, nil
+}
+
+func (c configCompiler) compilePipeline(node *configNode) (segment.Filter, error) {
+	elems, ok := node.value.([]*configNode)
+	if !ok {
+		return nil, c.errorf(node, "pipeline must be a list of stages")
+	}
+	filters := make([]segment.Filter, 0, len(elems))
+	for _, elem := range elems {
+		filter, err := c.compileStage(elem)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return FromFilters(filters...), nil
+}
+
+func (c configCompiler) compilePipelines(node *configNode) ([]segment.Filter, error) {
+	elems, ok := node.value.([]*configNode)
+	if !ok {
+		return nil, c.errorf(node, "expected a list of pipelines")
+	}
+	filters := make([]segment.Filter, 0, len(elems))
+	for _, elem := range elems {
+		filter, err := c.compilePipeline(elem)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func (c configCompiler) compileStage(node *configNode) (segment.Filter, error) {
+	object, ok := node.value.(*configObject)
+	if !ok || len(object.keys) != 1 {
+		return nil, c.errorf(node, "stage must be an object with a single key")
+	}
+	kind := object.keys[0]
+	value := object.values[kind]
+	switch kind {
+	case "acl":
+		acl := new(pathpol.ACL)
+		if err := json.Unmarshal(c.raw(value), acl); err != nil {
+			return nil, c.errorf(value, "invalid ACL: %s", err)
+		}
+		return FromACL(*acl), nil
+	case "sequence":
+		sequence, err := c.sequence(value)
+		if err != nil {
+			return nil, err
+		}
+		return FromSequence(*sequence), nil
+	case "enumerate":
+		return c.compileEnumerate(value)
+	case "topk":
+		params, err := c.params(value, "k", "metric")
+		if err != nil {
+			return nil, err
+		}
+		k, err := c.integer(value, params, "k")
+		if err != nil {
+			return nil, err
+		}
+		metric, err := c.metric(value, params)
+		if err != nil {
+			return nil, err
+		}
+		return TopK(k, metric), nil
+	case "threshold":
+		params, err := c.params(value, "max", "metric")
+		if err != nil {
+			return nil, err
+		}
+		max, err := c.number(value, params, "max")
+		if err != nil {
+			return nil, err
+		}
+		metric, err := c.metric(value, params)
+		if err != nil {
+			return nil, err
+		}
+		return Threshold(metric, max), nil
+	case "policy":
+		filename, err := c.filename(value)
+		if err != nil {
+			return nil, err
+		}
+		policy, err := LoadPolicy(filename)
+		if err != nil {
+			return nil, c.errorf(value, "%s", err)
+		}
+		return FromPolicy(policy), nil
+	case "pipeline":
+		filename, err := c.filename(value)
+		if err != nil {
+			return nil, err
+		}
+		filter, err := loadConfig(filename, c.loading)
+		if err != nil {
+			return nil, c.errorf(value, "%s", err)
+		}
+		return filter, nil
+	case "any", "all", "union", "intersect":
+		filters, err := c.compilePipelines(value)
+		if err != nil {
+			return nil, err
+		}
+		combinators := map[string]func(...segment.Filter) segment.Filter{
+			"any": Any, "all": All, "union": Union, "intersect": Intersect,
+		}
+		return combinators[kind](filters...), nil
+	case "not", "bidirectional":
+		filter, err := c.compilePipeline(value)
+		if err != nil {
+			return nil, err
+		}
+		if kind == "not" {
+			return Not(filter), nil
+		}
+		return Bidirectional(filter), nil
+	}
+	return nil, c.errorf(node, "unknown stage %q", kind)
+}
+
+func (c configCompiler) compileEnumerate(node *configNode) (segment.Filter, error) {
+	params, err := c.params(node, "max_segments", "shortcuts", "peering", "on_path", "loop_free", "sequence")
+	if err != nil {
+		return nil, err
+	}
+	opts := segment.EnumerationOptions{}
+	if _, ok := params["max_segments"]; ok {
+		if opts.MaxSegments, err = c.integer(node, params, "max_segments"); err != nil {
+			return nil, err
+		}
+	}
+	flags := map[string]*bool{
+		"shortcuts": &opts.Shortcuts,
+		"peering":   &opts.Peering,
+		"on_path":   &opts.OnPath,
+		"loop_free": &opts.LoopFree,
+	}
+	for key, flag := range flags {
+		if value, ok := params[key]; ok {
+			b, ok := value.value.(bool)
+			if !ok {
+				return nil, c.errorf(value, "%s must be a boolean", key)
+			}
+			*flag = b
+		}
+	}
+	if value, ok := params["sequence"]; ok {
+		sequence, err := c.sequence(value)
+		if err != nil {
+			return nil, err
+		}
+		return SequenceEnumeratorWithOptions(*sequence, opts), nil
+	}
+	return SrcDstPathEnumeratorWithOptions(opts), nil
+}
+
+// params returns the members of an object and checks that only the given
+// keys are used.
+func (c configCompiler) params(node *configNode, keys ...string) (map[string]*configNode, error) {
+	object, ok := node.value.(*configObject)
+	if !ok {
+		return nil, c.errorf(node, "expected an object with the keys %s", strings.Join(keys, ", "))
+	}
+	allowed := make(map[string]bool)
+	for _, key := range keys {
+		allowed[key] = true
+	}
+	for _, key := range object.keys {
+		if !allowed[key] {
+			sort.Strings(keys)
+			return nil, c.errorf(object.values[key], "unknown key %q, expected one of %s", key, strings.Join(keys, ", "))
+		}
+	}
+	return object.values, nil
+}
+
+func (c configCompiler) number(node *configNode, params map[string]*configNode, key string) (float64, error) {
+	value, ok := params[key]
+	if !ok {
+		return 0, c.errorf(node, "%s is missing", key)
+	}
+	number, ok := value.value.(json.Number)
+	if !ok {
+		return 0, c.errorf(value, "%s must be a number", key)
+	}
+	f, err := number.Float64()
+	if err != nil {
+		return 0, c.errorf(value, "%s must be a number", key)
+	}
+	return f, nil
+}
+
+func (c configCompiler) integer(node *configNode, params map[string]*configNode, key string) (int, error) {
+	value, ok := params[key]
+	if !ok {
+		return 0, c.errorf(node, "%s is missing", key)
+	}
+	number, ok := value.value.(json.Number)
+	if !ok {
+		return 0, c.errorf(value, "%s must be an integer", key)
+	}
+	i, err := number.Int64()
+	if err != nil || i < 0 {
+		return 0, c.errorf(value, "%s must be a non-negative integer", key)
+	}
+	return int(i), nil
+}
+
+func (c configCompiler) metric(node *configNode, params map[string]*configNode) (segment.Metric, error) {
+	value, ok := params["metric"]
+	if !ok {
+		return nil, c.errorf(node, "metric is missing")
+	}
+	switch value.value {
+	case "hops":
+		return segment.HopCount, nil
+	case "segments":
+		return segment.SegmentCount, nil
+	}
+	return nil, c.errorf(value, "unknown metric %s, expected \"hops\" or \"segments\"", c.raw(value))
+}
+
+func (c configCompiler) sequence(node *configNode) (*pathpol.Sequence, error) {
+	str, ok := node.value.(string)
+	if !ok {
+		return nil, c.errorf(node, "sequence must be a string")
+	}
+	sequence, err := pathpol.NewSequence(str)
+	if err != nil {
+		return nil, c.errorf(node, "invalid sequence: %s", err)
+	}
+	return sequence, nil
+}
+
+func (c configCompiler) filename(node *configNode) (string, error) {
+	filename, ok := node.value.(string)
+	if !ok || filename == "" {
+		return "", c.errorf(node, "expected a file path")
+	}
+	return resolvePath(c.dir, filename), nil
+}
+
+func (c configCompiler) raw(node *configNode) []byte {
+	return c.data[node.start:node.end]
+}
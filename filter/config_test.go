@@ -0,0 +1,85 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/pathpol"
+)
+
+func TestLoadPipeline(t *testing.T) {
+	dir := t.TempDir()
+	config := `{
+		"name": "client",
+		"pipeline": [
+			{"acl": ["- 1-ffaa:0:1000#2", "+"]},
+			{"enumerate": {"max_segments": 3, "loop_free": true}},
+			{"any": [
+				[{"sequence": "0* 2-ffaa:0:1000#1"}],
+				[{"not": [{"threshold": {"metric": "hops", "max": 20}}]}]
+			]},
+			{"topk": {"k": 3, "metric": "hops"}}
+		]
+	}`
+	filename := filepath.Join(dir, "pipeline.json")
+	if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acl := mustACL(`["- 1-ffaa:0:1000#2", "+"]`)
+	seq, _ := pathpol.NewSequence("0* 2-ffaa:0:1000#1")
+	expected := FromFilters(
+		FromACL(acl),
+		SrcDstPathEnumeratorWithOptions(segment.EnumerationOptions{MaxSegments: 3, LoopFree: true}),
+		Any(FromSequence(*seq), Not(Threshold(segment.HopCount, 20))),
+		TopK(3, segment.HopCount),
+	)
+	segset := enumeratedSegmentSet(3, 3)
+	segset.Segments = nil
+	for _, path := range enumeratedSegmentSet(3, 3).Segments {
+		segset.Segments = append(segset.Segments, path.(segment.Composition).Segments...)
+	}
+	segset = segset.Dedup(segment.Segment.Fingerprint)
+	want := expected.Filter(segset).Segments
+	have := loaded.Filter(segset).Segments
+	if len(want) == 0 || len(have) != len(want) {
+		t.Fatalf("want %d paths, have %d", len(want), len(have))
+	}
+	for i := range have {
+		if have[i].Fingerprint() != want[i].Fingerprint() {
+			t.Errorf("want: %s, have: %s", want[i], have[i])
+		}
+	}
+}
+
+func TestLoadPipelineErrors(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"{\n\"pipeline\": [\n{\"acl\": [\"+\"]},\n{\"enumerat\": {}}\n]\n}", ":4: unknown stage"},
+		{"{\n\"pipeline\": [\n{\"sequence\":\n\"19 (\"}\n]\n}", ":4: invalid sequence"},
+		{"{\n\"pipeline\": [\n{\"topk\": {\"k\": 3,\n\"metric\": \"latency\"}}\n]\n}", ":4: unknown metric"},
+		{"{\n\"pipeline\": [\n{\"acl\": [\"+\"]}\n{\"acl\": [\"+\"]}\n]\n}", ":4: invalid character"},
+		{"{\n\"pipeline\": [\n{\"threshold\": {\"max\": 3}}\n]\n}", ":3: metric is missing"},
+		{"{\n\"name\": \"client\"\n}", ":1: pipeline is missing"},
+	}
+	dir := t.TempDir()
+	for _, test := range tests {
+		filename := filepath.Join(dir, "pipeline.json")
+		if err := os.WriteFile(filename, []byte(test.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(filename)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("want error containing %q, have: %v", test.want, err)
+		}
+	}
+}
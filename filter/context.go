@@ -0,0 +1,41 @@
+package filter
+
+import (
+	"context"
+
+	"github.com/mblarer/conpass/segment"
+)
+
+// FromContextFunc returns a segment.Filter that applies a function which
+// depends on the negotiation in which the filter is applied. The filter
+// implements segment.ContextFilter. If it is applied outside of a negotiation,
+// the function is called with the zero segment.NegotiationInfo.
+func FromContextFunc(filter func(context.Context, segment.NegotiationInfo, segment.SegmentSet) segment.SegmentSet) segment.Filter {
+	return contextFunc{filter: filter}
+}
+
+type contextFunc struct {
+	filter func(context.Context, segment.NegotiationInfo, segment.SegmentSet) segment.SegmentSet
+}
+
+func (cf contextFunc) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return cf.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (cf contextFunc) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	return cf.filter(ctx, info, segset)
+}
+
+// ForPeer returns a segment.Filter that selects the filter to apply based on
+// the negotiation, e.g., a stricter filter for peers from unknown ISDs or a
+// more relaxed filter for authenticated partners. If the selected filter is
+// nil, all segments are rejected.
+func ForPeer(selectFilter func(segment.NegotiationInfo) segment.Filter) segment.Filter {
+	return FromContextFunc(func(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+		filter := selectFilter(info)
+		if filter == nil {
+			return segset.WithSegments([]segment.Segment{})
+		}
+		return segment.ApplyFilter(ctx, info, filter, segset)
+	})
+}
@@ -0,0 +1,138 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/mblarer/conpass/path"
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/pathpol"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// RuleKind is the kind of a Rule of a path policy.
+type RuleKind int
+
+const (
+	// RuleACLEntry is an entry of the ACL of a path policy.
+	RuleACLEntry RuleKind = iota
+	// RuleSequence is the sequence of a path policy.
+	RuleSequence
+)
+
+// Rule identifies a rule of the path policy of one of the agents that can be
+// relaxed. An ACL entry is relaxed by removing it, a sequence is relaxed by
+// dropping it. If the last remaining ACL entry of an interface is removed,
+// the interface is allowed.
+type Rule struct {
+	// Role is the role of the agent whose policy contains the rule.
+	Role segment.Role
+	// Kind is the kind of the rule.
+	Kind RuleKind
+	// Index is the index of an ACL entry in the ACL.
+	Index int
+	// Text is the string representation of the ACL entry or sequence.
+	Text string
+}
+
+func (r Rule) String() string {
+	if r.Kind == RuleSequence {
+		return fmt.Sprintf("%s sequence %q", r.Role, r.Text)
+	}
+	return fmt.Sprintf("%s ACL entry %d %q", r.Role, r.Index, r.Text)
+}
+
+// PathDiagnosis lists the rules that block an end-to-end path.
+type PathDiagnosis struct {
+	Path     segment.Segment
+	Blocking []Rule
+}
+
+// Diagnosis explains why a negotiation between two agents with the given path
+// policies yields no paths, see DiagnosePolicies.
+type Diagnosis struct {
+	// Paths are the end-to-end paths that can be constructed from the
+	// candidate segments, together with the rules that block them.
+	Paths []PathDiagnosis
+	// Relaxation is a minimum set of rules that need to be relaxed such that
+	// at least one path is accepted by both agents. It is empty if a path is
+	// already accepted or if there is no path at all.
+	Relaxation []Rule
+	// Unblocked is the path that is accepted after the relaxation, or nil if
+	// there is no path at all.
+	Unblocked segment.Segment
+}
+
+// DiagnosePolicies enumerates the end-to-end paths that can be constructed
+// from a set of candidate segments and determines for every path which ACL
+// entries and sequences of the path policies of the Initiator and the
+// Responder block it. A nil policy accepts every path.
+//
+// Only raw pathpol policies are diagnosed, not arbitrary segment.Filters such
+// as the filters of a negotiation configuration. The options of the policies
+// are ignored and the paths are enumerated with the default
+// segment.EnumerationOptions, see segment.SegmentSet.EnumeratePaths, hence
+// paths that require shortcuts, peering or on-path truncation are not
+// considered.
+//
+// Relaxing the blocking rules of a path is both necessary and sufficient for
+// the path to be accepted, hence the blocking rules of the path with the
+// fewest blocking rules are a minimum relaxation.
+func DiagnosePolicies(candidates segment.SegmentSet, initiator, responder *pathpol.Policy) Diagnosis {
+	diagnosis := Diagnosis{}
+	for _, spath := range candidates.EnumeratePaths() {
+		blocking := append(blockingRules(initiator, segment.RoleInitiator, spath),
+			blockingRules(responder, segment.RoleResponder, spath)...)
+		diagnosis.Paths = append(diagnosis.Paths, PathDiagnosis{Path: spath, Blocking: blocking})
+		if diagnosis.Unblocked == nil || len(blocking) < len(diagnosis.Relaxation) {
+			diagnosis.Unblocked = spath
+			diagnosis.Relaxation = blocking
+		}
+	}
+	return diagnosis
+}
+
+// blockingRules returns the rules of a policy that block a path.
+func blockingRules(policy *pathpol.Policy, role segment.Role, spath segment.Segment) []Rule {
+	rules := make([]Rule, 0)
+	if policy == nil {
+		return rules
+	}
+	interfaces := spath.PathInterfaces()
+	if policy.ACL != nil {
+		denied := make(map[int]bool)
+		for i, iface := range interfaces {
+			for _, index := range denyingEntries(*policy.ACL, iface, i%2 != 0) {
+				denied[index] = true
+			}
+		}
+		for index, entry := range policy.ACL.Entries {
+			if denied[index] {
+				rules = append(rules, Rule{Role: role, Kind: RuleACLEntry, Index: index, Text: entry.String()})
+			}
+		}
+	}
+	if policy.Sequence != nil {
+		ipath := path.InterfacePath{Interfaces: interfaces}
+		if len(policy.Sequence.Eval([]snet.Path{ipath})) == 0 {
+			rules = append(rules, Rule{Role: role, Kind: RuleSequence, Text: policy.Sequence.String()})
+		}
+	}
+	return rules
+}
+
+// denyingEntries returns the indices of the deny entries of an ACL that match
+// an interface before the first matching allow entry. These are the entries
+// that need to be removed for the interface to be allowed.
+func denyingEntries(acl pathpol.ACL, iface snet.PathInterface, ingress bool) []int {
+	indices := make([]int, 0)
+	for index, entry := range acl.Entries {
+		if entry.Rule != nil && !matchesInterface(entry.Rule, iface, ingress) {
+			continue
+		}
+		if entry.Action == pathpol.Allow {
+			break
+		}
+		indices = append(indices, index)
+	}
+	return indices
+}
@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/pathpol"
+)
+
+func TestDiagnosePolicies(t *testing.T) {
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1107")
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+		segment.FromString("17-ffaa:0:1108 2>1 17-ffaa:0:1102 2>1 17-ffaa:0:1107"),
+	}
+	candidates := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+
+	initiatorACL := mustACL(`["- 17-ffaa:0:1102", "- 17-ffaa:0:1101", "+"]`)
+	responderACL := mustACL(`["+ 17-ffaa:0:1101", "- 17", "+"]`)
+	sequence, _ := pathpol.NewSequence("0* 17-ffaa:0:1101 0*")
+	initiator := &pathpol.Policy{ACL: &initiatorACL}
+	responder := &pathpol.Policy{ACL: &responderACL, Sequence: sequence}
+
+	diagnosis := DiagnosePolicies(candidates, initiator, responder)
+	if len(diagnosis.Paths) != 2 {
+		t.Fatalf("want 2 paths, have %d", len(diagnosis.Paths))
+	}
+	want := [][]Rule{
+		{
+			{Role: segment.RoleInitiator, Kind: RuleACLEntry, Index: 0, Text: "- 17-ffaa:0:1102#0"},
+			{Role: segment.RoleResponder, Kind: RuleACLEntry, Index: 1, Text: "- 17-0#0"},
+			{Role: segment.RoleResponder, Kind: RuleSequence, Text: "0* 17-ffaa:0:1101 0*"},
+		},
+		{
+			{Role: segment.RoleInitiator, Kind: RuleACLEntry, Index: 0, Text: "- 17-ffaa:0:1102#0"},
+			{Role: segment.RoleInitiator, Kind: RuleACLEntry, Index: 1, Text: "- 17-ffaa:0:1101#0"},
+			{Role: segment.RoleResponder, Kind: RuleACLEntry, Index: 1, Text: "- 17-0#0"},
+		},
+	}
+	for i, pd := range diagnosis.Paths {
+		if len(pd.Blocking) != len(want[i]) {
+			t.Errorf("path %s: want %v, have %v", pd.Path, want[i], pd.Blocking)
+			continue
+		}
+		for j := range pd.Blocking {
+			if pd.Blocking[j] != want[i][j] {
+				t.Errorf("path %s: want %v, have %v", pd.Path, want[i][j], pd.Blocking[j])
+			}
+		}
+	}
+	if len(diagnosis.Relaxation) != 3 || diagnosis.Unblocked.Fingerprint() != diagnosis.Paths[0].Path.Fingerprint() {
+		t.Errorf("want relaxation of first path, have %v for %s", diagnosis.Relaxation, diagnosis.Unblocked)
+	}
+
+	relaxedACL := mustACL(`["+"]`)
+	relaxed := DiagnosePolicies(candidates, &pathpol.Policy{ACL: &relaxedACL}, nil)
+	if len(relaxed.Relaxation) != 0 || relaxed.Unblocked == nil {
+		t.Errorf("want empty relaxation, have %v", relaxed.Relaxation)
+	}
+}
@@ -1,6 +1,10 @@
 package filter
 
-import "github.com/mblarer/conpass/segment"
+import (
+	"fmt"
+
+	"github.com/mblarer/conpass/segment"
+)
 
 // SrcDstPathEnumerator returns a segment.Filter that enumerates all paths
 // between the given source ISD-AS and the destination ISD-AS that can be
@@ -9,12 +13,53 @@ func SrcDstPathEnumerator() segment.Filter {
 	return pathEnumerator{}
 }
 
-type pathEnumerator struct{}
+// SrcDstPathEnumeratorWithOptions returns a segment.Filter that enumerates all
+// paths between the given source ISD-AS and the destination ISD-AS that can
+// be constructed from the given path segments according to the given
+// segment.EnumerationOptions.
+func SrcDstPathEnumeratorWithOptions(opts segment.EnumerationOptions) segment.Filter {
+	return pathEnumerator{opts: opts}
+}
+
+type pathEnumerator struct {
+	opts segment.EnumerationOptions
+}
+
+func (pe pathEnumerator) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return segset.WithSegments(segset.EnumeratePathsWithOptions(pe.opts))
+}
+
+func (pe pathEnumerator) String() string {
+	return "enumerate"
+}
+
+// FirstPaths returns a segment.Filter that lazily enumerates paths between the
+// given source ISD-AS and the destination ISD-AS according to the given
+// segment.EnumerationOptions and keeps the first n paths that satisfy a given
+// predicate. The enumeration stops as soon as n paths have been found.
+func FirstPaths(n int, opts segment.EnumerationOptions, accept func(segment.Segment) bool) segment.Filter {
+	return firstPaths{n: n, opts: opts, accept: accept}
+}
 
-func (_ pathEnumerator) Filter(segset segment.SegmentSet) segment.SegmentSet {
-	return segment.SegmentSet{
-		Segments: segset.EnumeratePaths(),
-		SrcIA:    segset.SrcIA,
-		DstIA:    segset.DstIA,
+type firstPaths struct {
+	n      int
+	opts   segment.EnumerationOptions
+	accept func(segment.Segment) bool
+}
+
+func (fp firstPaths) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	paths := make([]segment.Segment, 0, fp.n)
+	if fp.n > 0 {
+		segment.EnumeratePathsWithOptions(segset, fp.opts, func(path segment.Segment) bool {
+			if fp.accept(path) {
+				paths = append(paths, path)
+			}
+			return len(paths) < fp.n
+		})
 	}
+	return segset.WithSegments(paths)
+}
+
+func (fp firstPaths) String() string {
+	return fmt.Sprintf("first %d", fp.n)
 }
@@ -0,0 +1,85 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/pathpol"
+)
+
+// Files are the files from which the filter of an agent is loaded, see
+// LoadFiles. Empty file paths are ignored.
+type Files struct {
+	// Rules is a file with rules that route to filters per peer, see
+	// LoadRouter.
+	Rules string
+	// Pipeline is a pipeline configuration file, see Load.
+	Pipeline string
+	// Policy is a pathpol policy file, see LoadPolicy.
+	Policy string
+	// ACL is a JSON file with a pathpol ACL.
+	ACL string
+	// Sequence is a JSON file with a pathpol sequence.
+	Sequence string
+}
+
+// LoadFiles loads the filter from the first of the rules, pipeline and policy
+// files that is given. If none of them is given, the filter is a policy that
+// consists of the ACL and sequence files, if given.
+func LoadFiles(files Files) (segment.Filter, error) {
+	if files.Rules != "" {
+		router, err := LoadRouter(files.Rules)
+		if err != nil {
+			return nil, err
+		}
+		return router, nil
+	}
+	if files.Pipeline != "" {
+		return Load(files.Pipeline)
+	}
+	if files.Policy != "" {
+		policy, err := LoadPolicy(files.Policy)
+		if err != nil {
+			return nil, err
+		}
+		return FromPolicy(policy), nil
+	}
+	policy := &pathpol.Policy{}
+	if files.ACL != "" {
+		policy.ACL = new(pathpol.ACL)
+		if err := loadJSON(files.ACL, policy.ACL); err != nil {
+			return nil, err
+		}
+	}
+	if files.Sequence != "" {
+		policy.Sequence = new(pathpol.Sequence)
+		if err := loadJSON(files.Sequence, policy.Sequence); err != nil {
+			return nil, err
+		}
+	}
+	return FromPolicy(policy), nil
+}
+
+// Paths returns the file paths that are given.
+func (files Files) Paths() []string {
+	paths := make([]string, 0)
+	for _, path := range []string{files.Rules, files.Pipeline, files.Policy, files.ACL, files.Sequence} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func loadJSON(filename string, v interface{}) error {
+	bytes, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bytes, v); err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	return nil
+}
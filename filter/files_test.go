@@ -0,0 +1,57 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := Files{
+		ACL:      filepath.Join(dir, "acl.json"),
+		Sequence: filepath.Join(dir, "seq.json"),
+	}
+	for filename, content := range map[string]string{
+		files.ACL:      `["- 17-ffaa:0:1101", "+"]`,
+		files.Sequence: `"19-ffaa:0:1303 0* 17-ffaa:0:1108"`,
+	} {
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := LoadFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files.Paths()) != 2 {
+		t.Error("want 2 paths, have:", files.Paths())
+	}
+
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segset := segment.SegmentSet{
+		Segments: []segment.Segment{
+			segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+			segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+			segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+		},
+		SrcIA: srcIA,
+		DstIA: dstIA,
+	}
+	want := segment.FromSegments(segset.Segments[0], segset.Segments[1])
+	have := loaded.Filter(segset).Segments
+	if len(have) != 1 || segment.InterfaceFingerprint(have[0]) != segment.InterfaceFingerprint(want) {
+		t.Errorf("want: %s, have: %v", want, have)
+	}
+
+	if err := os.WriteFile(files.ACL, []byte(`["invalid"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFiles(files); err == nil {
+		t.Error("want error for invalid ACL file, have none")
+	}
+}
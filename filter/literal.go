@@ -0,0 +1,21 @@
+package filter
+
+import "github.com/mblarer/conpass/segment"
+
+// FromLiteralPredicate returns a segment.Filter that keeps path segments if
+// and only if all of their segment literals satisfy a given predicate. This
+// allows, e.g., to reason about the type of the literals:
+//
+//	filter.FromLiteralPredicate(func(l segment.Literal) bool {
+//		return l.Type != segment.TypePeering
+//	})
+func FromLiteralPredicate(accept func(segment.Literal) bool) segment.Filter {
+	return FromPredicate(func(seg segment.Segment) bool {
+		for _, literal := range segment.Literals(seg) {
+			if !accept(literal) {
+				return false
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/pathpol"
+)
+
+// FromPolicy returns a segment.Filter that filters path segments according to
+// a pathpol.Policy, with the same semantics as pathpol.Policy.Filter: the ACL
+// is applied first, followed by the sequence and the weighted options. If the
+// policy or one of its options contains a sequence, the end-to-end paths are
+// enumerated from the remaining segments, see SequenceEnumerator. Otherwise,
+// the policy is applied to the segments as they are.
+func FromPolicy(policy *pathpol.Policy) segment.Filter {
+	return policyFilter{policy: policy}
+}
+
+type policyFilter struct {
+	policy *pathpol.Policy
+}
+
+func (pf policyFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return applyPolicy(pf.policy, segset, false)
+}
+
+func (pf policyFilter) String() string {
+	if pf.policy == nil || pf.policy.Name == "" {
+		return "policy"
+	}
+	return fmt.Sprintf("policy %q", pf.policy.Name)
+}
+
+// applyPolicy applies a policy to a SegmentSet. If paths is true, the
+// SegmentSet already consists of end-to-end paths.
+func applyPolicy(policy *pathpol.Policy, segset segment.SegmentSet, paths bool) segment.SegmentSet {
+	if policy == nil {
+		return segset
+	}
+	if policy.ACL != nil {
+		segset = FromACL(*policy.ACL).Filter(segset)
+	}
+	switch {
+	case paths && policy.Sequence != nil:
+		segset = FromSequence(*policy.Sequence).Filter(segset)
+	case !paths && requiresPaths(policy):
+		sequence := pathpol.Sequence{}
+		if policy.Sequence != nil {
+			sequence = *policy.Sequence
+		}
+		segset = SequenceEnumerator(sequence).Filter(segset)
+		paths = true
+	}
+	return applyOptions(policy.Options, segset, paths)
+}
+
+// applyOptions keeps the segments that are accepted by any of the options with
+// the highest weight that accept at least one segment.
+func applyOptions(options []pathpol.Option, segset segment.SegmentSet, paths bool) segment.SegmentSet {
+	if len(options) == 0 {
+		return segset
+	}
+	sorted := append([]pathpol.Option(nil), options...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Weight > sorted[j].Weight
+	})
+	accepted := segset.WithSegments(nil)
+	currWeight := sorted[0].Weight
+	for _, option := range sorted {
+		if currWeight > option.Weight && len(accepted.Segments) > 0 {
+			break
+		}
+		currWeight = option.Weight
+		var policy *pathpol.Policy
+		if option.Policy != nil {
+			policy = option.Policy.Policy
+		}
+		result := applyPolicy(policy, segset, paths)
+		accepted = accepted.Union(result, segment.InterfaceFingerprint)
+	}
+	return segset.Intersect(accepted, segment.InterfaceFingerprint)
+}
+
+// requiresPaths reports whether a policy or one of its options contains a
+// sequence, which can only be evaluated on end-to-end paths.
+func requiresPaths(policy *pathpol.Policy) bool {
+	if policy == nil {
+		return false
+	}
+	if policy.Sequence != nil && policy.Sequence.String() != "" {
+		return true
+	}
+	for _, option := range policy.Options {
+		if option.Policy != nil && requiresPaths(option.Policy.Policy) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadPolicy reads a pathpol.Policy from a JSON file. Policies that are
+// referenced in "extends" are loaded from the same directory, from a file
+// with the name of the policy and, unless the name already contains one, the
+// extension ".json". Extended policies are resolved recursively, also for the
+// policies of options.
+func LoadPolicy(filename string) (*pathpol.Policy, error) {
+	dir := filepath.Dir(filename)
+	name := strings.TrimSuffix(filepath.Base(filename), ".json")
+	return loadPolicy(dir, name, filename, map[string]bool{})
+}
+
+func loadPolicy(dir, name, filename string, loading map[string]bool) (*pathpol.Policy, error) {
+	if loading[filename] {
+		return nil, fmt.Errorf("policy %q extends itself", name)
+	}
+	loading[filename] = true
+	defer delete(loading, filename)
+
+	bytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	extPolicy := new(pathpol.ExtPolicy)
+	if err := json.Unmarshal(bytes, extPolicy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy %q: %s", filename, err)
+	}
+	return resolvePolicy(dir, name, extPolicy, loading)
+}
+
+func resolvePolicy(dir, name string, extPolicy *pathpol.ExtPolicy, loading map[string]bool) (*pathpol.Policy, error) {
+	if extPolicy.Policy == nil {
+		extPolicy.Policy = &pathpol.Policy{}
+	}
+	extPolicy.Name = name
+	extended := make([]*pathpol.ExtPolicy, 0, len(extPolicy.Extends))
+	for _, extName := range extPolicy.Extends {
+		filename := extName
+		if filepath.Ext(filename) == "" {
+			filename += ".json"
+		}
+		policy, err := loadPolicy(dir, extName, filepath.Join(dir, filename), loading)
+		if err != nil {
+			return nil, err
+		}
+		extended = append(extended, &pathpol.ExtPolicy{Policy: policy})
+	}
+	policy, err := pathpol.PolicyFromExtPolicy(extPolicy, extended)
+	if err != nil {
+		return nil, err
+	}
+	// The options may be shared with an extended policy, hence they are
+	// copied before they are resolved.
+	policy.Options = append([]pathpol.Option(nil), policy.Options...)
+	for i, option := range policy.Options {
+		if option.Policy == nil {
+			continue
+		}
+		optName := fmt.Sprintf("%s.options[%d]", name, i)
+		resolved, err := resolvePolicy(dir, optName, copyExtPolicy(option.Policy), loading)
+		if err != nil {
+			return nil, err
+		}
+		policy.Options[i].Policy = &pathpol.ExtPolicy{Policy: resolved}
+	}
+	policy.Name = name
+	return policy, nil
+}
+
+// copyExtPolicy returns a copy of an ExtPolicy and its policy, such that
+// resolving the copy does not modify the original.
+func copyExtPolicy(extPolicy *pathpol.ExtPolicy) *pathpol.ExtPolicy {
+	copied := *extPolicy
+	if copied.Policy != nil {
+		policy := *copied.Policy
+		copied.Policy = &policy
+	}
+	return &copied
+}
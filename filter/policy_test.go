@@ -0,0 +1,99 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/pathpol"
+)
+
+func TestPolicyFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.json": `{
+			"acl": ["- 17-ffaa:0:1101", "+"]
+		}`,
+		"policy.json": `{
+			"extends": ["base"],
+			"sequence": "19-ffaa:0:1303 0* 17-ffaa:0:1107",
+			"options": [
+				{"weight": 0, "policy": {}},
+				{"weight": 1, "policy": {"sequence": "0* 17-ffaa:0:1102 0*"}},
+				{"weight": 2, "policy": {"extends": ["never"]}}
+			]
+		}`,
+		"never.json": `{
+			"sequence": "0* 17-ffaa:0:1104 0*"
+		}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	policy, err := LoadPolicy(filepath.Join(dir, "policy.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1107")
+	segset := segment.SegmentSet{
+		Segments: []segment.Segment{
+			segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+			segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+			segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+			segment.FromString("17-ffaa:0:1108 2>1 17-ffaa:0:1102 2>1 17-ffaa:0:1107"),
+			segment.FromString("17-ffaa:0:1108 3>2 17-ffaa:0:1107"),
+		},
+		SrcIA: srcIA,
+		DstIA: dstIA,
+	}
+	want := []segment.Segment{
+		segment.FromSegments(segset.Segments[0], segset.Segments[1], segset.Segments[3]),
+	}
+	have := FromPolicy(policy).Filter(segset).Segments
+	if len(have) != len(want) {
+		t.Fatalf("want %d paths, have %d: %v", len(want), len(have), have)
+	}
+	for i := range have {
+		if segment.InterfaceFingerprint(have[i]) != segment.InterfaceFingerprint(want[i]) {
+			t.Errorf("want: %s, have: %s", want[i], have[i])
+		}
+	}
+}
+
+func TestPolicyExtendsCycle(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.json": `{"extends": ["b"]}`,
+		"b.json": `{"extends": ["a"]}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := LoadPolicy(filepath.Join(dir, "a.json")); err == nil {
+		t.Error("want error for cyclic extends, have none")
+	}
+}
+
+func TestResolvePolicyKeepsSharedOptions(t *testing.T) {
+	acl := mustACL(`["- 1-ffaa:0:1000", "+"]`)
+	option := &pathpol.ExtPolicy{Policy: &pathpol.Policy{ACL: &acl}}
+	options := []pathpol.Option{{Weight: 1, Policy: option}}
+	extPolicy := &pathpol.ExtPolicy{Policy: &pathpol.Policy{Options: options}}
+	policy, err := resolvePolicy(t.TempDir(), "shared", extPolicy, map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Options[0].Policy.Name != "shared.options[0]" {
+		t.Error("want resolved option name, have:", policy.Options[0].Policy.Name)
+	}
+	if options[0].Policy != option || option.Name != "" {
+		t.Error("resolving the policy modified the shared options")
+	}
+}
@@ -19,9 +19,5 @@ func (pf predicateFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
 			filtered = append(filtered, segment)
 		}
 	}
-	return segment.SegmentSet{
-		Segments: filtered,
-		SrcIA:    segset.SrcIA,
-		DstIA:    segset.DstIA,
-	}
+	return segset.WithSegments(filtered)
 }
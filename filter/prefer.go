@@ -0,0 +1,31 @@
+package filter
+
+import "github.com/mblarer/conpass/segment"
+
+// Prefer returns a segment.Filter that keeps all segments and assigns them the
+// preference score returned by the given function, where a higher score is
+// better, see segment.SegmentSet.Preferences. Scores that were assigned
+// before are replaced.
+func Prefer(score func(segment.Segment) float64) segment.Filter {
+	return preferFilter{score: score}
+}
+
+type preferFilter struct {
+	score func(segment.Segment) float64
+}
+
+func (pf preferFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	preferences := make(map[string]float64, len(segset.Preferences)+len(segset.Segments))
+	for key, score := range segset.Preferences {
+		preferences[key] = score
+	}
+	for _, seg := range segset.Segments {
+		preferences[segment.InterfaceFingerprint(seg)] = pf.score(seg)
+	}
+	segset.Preferences = preferences
+	return segset
+}
+
+func (pf preferFilter) String() string {
+	return "prefer"
+}
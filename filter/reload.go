@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mblarer/conpass/segment"
+)
+
+// Reloader is a segment.Filter that delegates to a filter which can be
+// replaced at runtime, e.g., after a policy file has changed. A new filter is
+// obtained from a load function and swapped in atomically. Negotiations that
+// take a snapshot of the Reloader when they start, as the CONPASS agents do,
+// finish with the filter of that snapshot.
+type Reloader struct {
+	load    func() (segment.Filter, error)
+	current atomic.Value // holds a reloadedFilter
+	mutex   sync.Mutex   // serializes reloads
+}
+
+type reloadedFilter struct {
+	filter segment.Filter
+}
+
+// NewReloader returns a Reloader with the filter that is returned by the
+// given load function, or an error if the filter cannot be loaded.
+func NewReloader(load func() (segment.Filter, error)) (*Reloader, error) {
+	r := &Reloader{load: load}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads a new filter and swaps it in. If the filter cannot be loaded,
+// the current filter remains in place and the error is returned.
+func (r *Reloader) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	filter, err := r.load()
+	if err != nil {
+		return err
+	}
+	r.current.Store(reloadedFilter{filter: filter})
+	return nil
+}
+
+// Snapshot returns the current filter, which is not affected by later
+// reloads.
+func (r *Reloader) Snapshot() segment.Filter {
+	return r.current.Load().(reloadedFilter).filter
+}
+
+func (r *Reloader) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return r.Snapshot().Filter(segset)
+}
+
+func (r *Reloader) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	return segment.ApplyFilter(ctx, info, r.Snapshot(), segset)
+}
+
+// WatchFiles polls the modification times of the given files at the given
+// interval and reloads the filter when one of them has changed, until the
+// context is done. The filter is also reloaded at the first poll, such that
+// changes before the call are not missed. Files that are referenced by the
+// given files, e.g., extended policies, are not watched. Reload failures are
+// logged.
+func (r *Reloader) WatchFiles(ctx context.Context, interval time.Duration, filenames ...string) {
+	modTimes := make([]time.Time, len(filenames))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed := false
+		for i, filename := range filenames {
+			if t := modTime(filename); !t.Equal(modTimes[i]) {
+				modTimes[i] = t
+				changed = true
+			}
+		}
+		if changed {
+			r.reloadAndLog("file change")
+		}
+	}
+}
+
+// ReloadOnSignal reloads the filter whenever the process receives one of the
+// given signals, or SIGHUP if no signal is given, until the context is done.
+// Reload failures are logged.
+func (r *Reloader) ReloadOnSignal(ctx context.Context, signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	channel := make(chan os.Signal, 1)
+	signal.Notify(channel, signals...)
+	defer signal.Stop(channel)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-channel:
+			r.reloadAndLog(sig.String())
+		}
+	}
+}
+
+func (r *Reloader) reloadAndLog(cause string) {
+	if err := r.Reload(); err != nil {
+		log.Printf("failed to reload filter after %s, keeping the old filter: %s", cause, err)
+		return
+	}
+	log.Printf("reloaded filter after %s", cause)
+}
+
+// modTime returns the modification time of a file, or the zero time if the
+// file cannot be accessed.
+func modTime(filename string) time.Time {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,70 @@
+package filter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+func TestReloaderWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "pipeline.json")
+	write := func(content string, modTime time.Time) {
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filename, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
+	dstIA, _ := addr.IAFromString("1-ffaa:0:2")
+	segset := segment.SegmentSet{
+		Segments: []segment.Segment{segment.FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")},
+		SrcIA:    srcIA,
+		DstIA:    dstIA,
+	}
+	accepted := func(filter segment.Filter) int {
+		return len(filter.Filter(segset).Segments)
+	}
+
+	now := time.Now()
+	write(`{"pipeline": [{"acl": ["+"]}]}`, now.Add(-time.Hour))
+	reloader, err := NewReloader(func() (segment.Filter, error) { return Load(filename) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := reloader.Snapshot()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.WatchFiles(ctx, time.Millisecond, filename)
+
+	write(`{"pipeline": [{"acl": ["-"]}]}`, now)
+	waitFor(t, func() bool { return accepted(reloader) == 0 })
+	if accepted(old) != 1 {
+		t.Error("snapshot has changed after reload")
+	}
+
+	write(`{"pipeline": [{"acl": [`, now.Add(time.Hour))
+	if err := reloader.Reload(); err == nil {
+		t.Error("want error for invalid pipeline, have none")
+	}
+	if accepted(reloader) != 0 {
+		t.Error("filter has changed after failed reload")
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not satisfied before deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,161 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+// Route is a rule of a Router. An ISD or AS of 0 in SrcIA or DstIA is a
+// wildcard, and an empty PeerIdentity matches any peer, including peers that
+// are not authenticated.
+type Route struct {
+	// SrcIA is matched against the source ISD-AS of the SegmentSet.
+	SrcIA addr.IA
+	// DstIA is matched against the destination ISD-AS of the SegmentSet.
+	DstIA addr.IA
+	// PeerIdentity is matched against the authenticated identity of the peer,
+	// which is only set if the certificate of the peer was verified, see
+	// segment.NegotiationInfo.
+	PeerIdentity string
+	// Filter is applied to the SegmentSet if the Route matches.
+	Filter segment.Filter
+}
+
+// Matches reports whether the Route matches a SegmentSet that is filtered in
+// the given negotiation.
+func (r Route) Matches(info segment.NegotiationInfo, segset segment.SegmentSet) bool {
+	return matchesIA(r.SrcIA, segset.SrcIA) && matchesIA(r.DstIA, segset.DstIA) &&
+		(r.PeerIdentity == "" || r.PeerIdentity == info.PeerIdentity)
+}
+
+func matchesIA(pattern, ia addr.IA) bool {
+	return (pattern.I == 0 || pattern.I == ia.I) && (pattern.A == 0 || pattern.A == ia.A)
+}
+
+// Router is a segment.Filter that selects the filter to apply from a table of
+// routes, such that a Responder can apply different consent rules to
+// different peers. The first matching Route is applied. If no Route matches,
+// the Default filter is applied, or all segments are rejected if there is no
+// Default filter.
+type Router struct {
+	Routes  []Route
+	Default segment.Filter
+}
+
+func (r Router) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return r.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (r Router) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	filter := r.Default
+	for _, route := range r.Routes {
+		if route.Matches(info, segset) {
+			filter = route.Filter
+			break
+		}
+	}
+	if filter == nil {
+		return segset.WithSegments([]segment.Segment{})
+	}
+	return segment.ApplyFilter(ctx, info, filter, segset)
+}
+
+type routerConfig struct {
+	Default string        `json:"default"`
+	Routes  []routeConfig `json:"routes"`
+}
+
+type routeConfig struct {
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+	Identity string `json:"identity"`
+	Policy   string `json:"policy"`
+	Pipeline string `json:"pipeline"`
+}
+
+// LoadRouter reads a Router from a JSON file of the following form, where
+// the policies are path policy files, see LoadPolicy, whose paths are
+// relative to the directory of the file. Instead of a policy, a route can
+// refer to a pipeline configuration file, see Load.
+//
+//	{
+//	  "default": "anonymous.json",
+//	  "routes": [
+//	    {"identity": "partner.example.org", "policy": "partner.json"},
+//	    {"src": "17-0", "policy": "customer.json"}
+//	  ]
+//	}
+//
+// Omitted ISD-AS addresses match any ISD-AS. If the default policy is
+// omitted, requests that match no route are rejected.
+func LoadRouter(filename string) (*Router, error) {
+	bytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := routerConfig{}
+	if err := json.Unmarshal(bytes, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse router %q: %s", filename, err)
+	}
+	dir := filepath.Dir(filename)
+	router := &Router{Routes: make([]Route, 0, len(config.Routes))}
+	if config.Default != "" {
+		if router.Default, err = loadPolicyFilter(dir, config.Default); err != nil {
+			return nil, err
+		}
+	}
+	for i, rc := range config.Routes {
+		route := Route{PeerIdentity: rc.Identity}
+		if route.SrcIA, err = parseIAPattern(rc.Src); err != nil {
+			return nil, fmt.Errorf("route %d: invalid src: %s", i, err)
+		}
+		if route.DstIA, err = parseIAPattern(rc.Dst); err != nil {
+			return nil, fmt.Errorf("route %d: invalid dst: %s", i, err)
+		}
+		switch {
+		case rc.Policy != "" && rc.Pipeline != "":
+			return nil, fmt.Errorf("route %d: policy and pipeline are exclusive", i)
+		case rc.Policy != "":
+			route.Filter, err = loadPolicyFilter(dir, rc.Policy)
+		case rc.Pipeline != "":
+			route.Filter, err = Load(resolvePath(dir, rc.Pipeline))
+		default:
+			err = fmt.Errorf("policy or pipeline is missing")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("route %d: %s", i, err)
+		}
+		router.Routes = append(router.Routes, route)
+	}
+	return router, nil
+}
+
+func parseIAPattern(str string) (addr.IA, error) {
+	if str == "" {
+		return addr.IA{}, nil
+	}
+	return addr.IAFromString(str)
+}
+
+func loadPolicyFilter(dir, filename string) (segment.Filter, error) {
+	policy, err := LoadPolicy(resolvePath(dir, filename))
+	if err != nil {
+		return nil, err
+	}
+	return FromPolicy(policy), nil
+}
+
+// resolvePath resolves a file path relative to the given directory, unless
+// it is absolute.
+func resolvePath(dir, filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(dir, filename)
+}
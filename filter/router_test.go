@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+func TestRouterFromFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"router.json": `{
+			"default": "anonymous.json",
+			"routes": [
+				{"identity": "partner.example.org", "policy": "partner.json"},
+				{"src": "17-0", "policy": "customer.json"}
+			]
+		}`,
+		"anonymous.json": `{"acl": ["-"]}`,
+		"partner.json":   `{}`,
+		"customer.json":  `{"acl": ["- 17-ffaa:0:1101", "+"]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router, err := LoadRouter(filepath.Join(dir, "router.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dstIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	segments := []segment.Segment{
+		segment.FromString("17-ffaa:0:1108 1>1 19-ffaa:0:1303"),
+		segment.FromString("17-ffaa:0:1108 2>1 17-ffaa:0:1101 2>2 19-ffaa:0:1303"),
+	}
+	tests := []struct {
+		name     string
+		src      string
+		identity string
+		want     int
+	}{
+		{"partner", "18-ffaa:0:1", "partner.example.org", 2},
+		{"customer", "17-ffaa:0:1108", "", 1},
+		{"partner before customer", "17-ffaa:0:1108", "partner.example.org", 2},
+		{"anonymous", "18-ffaa:0:1", "", 0},
+	}
+	for _, test := range tests {
+		srcIA, _ := addr.IAFromString(test.src)
+		segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+		info := segment.NegotiationInfo{PeerIA: srcIA, PeerIdentity: test.identity}
+		have := router.FilterWithContext(context.Background(), info, segset).Segments
+		if len(have) != test.want {
+			t.Errorf("%s: want %d segments, have %d", test.name, test.want, len(have))
+		}
+	}
+}
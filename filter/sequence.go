@@ -1,6 +1,8 @@
 package filter
 
 import (
+	"fmt"
+
 	"github.com/mblarer/conpass/path"
 	"github.com/mblarer/conpass/segment"
 	"github.com/scionproto/scion/go/lib/pathpol"
@@ -22,6 +24,55 @@ func (sf sequenceFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
 		path := path.InterfacePath{segment.PathInterfaces()}
 		result := sf.sequence.Eval([]snet.Path{path})
 		accept := len(result) == 1
+		if !accept {
+			segset.Trace.Explain(segment, fmt.Sprintf("does not match %q", sf.sequence.String()))
+		}
 		return accept
 	}).Filter(segset)
 }
+
+func (sf sequenceFilter) String() string {
+	return fmt.Sprintf("sequence %q", sf.sequence.String())
+}
+
+// SequenceEnumerator returns a segment.Filter that enumerates the paths
+// between the given source ISD-AS and the destination ISD-AS that satisfy a
+// given pathpol.Sequence policy. It yields the same result as applying
+// SrcDstPathEnumerator followed by FromSequence, but the sequence is compiled
+// into an automaton that is evaluated while the paths are constructed, such
+// that partial paths that can never satisfy the sequence are pruned early.
+func SequenceEnumerator(sequence pathpol.Sequence) segment.Filter {
+	return SequenceEnumeratorWithOptions(sequence, segment.EnumerationOptions{})
+}
+
+// SequenceEnumeratorWithOptions is like SequenceEnumerator, but constructs the
+// paths according to the given segment.EnumerationOptions.
+func SequenceEnumeratorWithOptions(sequence pathpol.Sequence, opts segment.EnumerationOptions) segment.Filter {
+	if sequence.String() == "" { // the empty sequence accepts every path
+		return SrcDstPathEnumeratorWithOptions(opts)
+	}
+	automaton, err := compileSequence(sequence.String())
+	if err != nil { // should not happen for a valid pathpol.Sequence
+		return FromFilters(SrcDstPathEnumeratorWithOptions(opts), FromSequence(sequence))
+	}
+	return sequenceEnumerator{sequence: sequence, automaton: automaton, opts: opts}
+}
+
+type sequenceEnumerator struct {
+	sequence  pathpol.Sequence
+	automaton *sequenceAutomaton
+	opts      segment.EnumerationOptions
+}
+
+func (se sequenceEnumerator) String() string {
+	return fmt.Sprintf("sequence %q", se.sequence.String())
+}
+
+func (se sequenceEnumerator) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	paths := make([]segment.Segment, 0)
+	segment.EnumerateMatchingPaths(segset, se.opts, se.automaton, func(path segment.Segment) bool {
+		paths = append(paths, path)
+		return true
+	})
+	return segset.WithSegments(paths)
+}
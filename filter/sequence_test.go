@@ -0,0 +1,61 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/mblarer/conpass/internal"
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/pathpol"
+)
+
+func TestSequenceEnumeratorMatchesSequenceFilter(t *testing.T) {
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	coreIA, _ := addr.IAFromString("19-ffaa:0:1302")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1107")
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1303 2>1 19-ffaa:0:1301 2>2 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+		segment.FromString("17-ffaa:0:1108 2>1 17-ffaa:0:1102 2>1 17-ffaa:0:1107"),
+		segment.FromString("17-ffaa:0:1108 3>2 17-ffaa:0:1107"),
+		segment.FromString("19-ffaa:0:1302 4>3 17-ffaa:0:1107"),
+	}
+	segments = append(segments, internal.GenerateSegments(3, 4, coreIA, dstIA)...)
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+
+	sequences := []string{
+		``,
+		`0*`,
+		`19* 17*`,
+		`17* 19*`,
+		`19 19 19+`,
+		`19-ffaa:0:1303 0* 17-ffaa:0:1107`,
+		`0+ 17-ffaa:0:1102#1 0*`,
+		`0* 17-ffaa:0:1102#2,1 0*`,
+		`0* 17-ffaa:0:1101#0,2 0*`,
+		`(19|17)+`,
+		`19-ffaa:0:1303#0,2 0* 17-ffaa:0:1107#2,0`,
+		`0 0 0?`,
+		`19 19 0 | 17 17 0*`,
+		`19+ (17-ffaa:0:1108 | 17-ffaa:0:1101)* 0 0?`,
+	}
+	for _, str := range sequences {
+		seq, err := pathpol.NewSequence(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := FromFilters(SrcDstPathEnumerator(), FromSequence(*seq)).Filter(segset).Segments
+		have := SequenceEnumerator(*seq).Filter(segset).Segments
+		if len(have) != len(want) {
+			t.Errorf("%q: want %d paths, have %d", str, len(want), len(have))
+			continue
+		}
+		for i := range have {
+			if have[i].Fingerprint() != want[i].Fingerprint() {
+				t.Errorf("%q: want: %s, have: %s", str, want[i], have[i])
+			}
+		}
+	}
+}
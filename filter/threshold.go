@@ -0,0 +1,11 @@
+package filter
+
+import "github.com/mblarer/conpass/segment"
+
+// Threshold returns a segment.Filter that keeps the segments whose cost
+// according to a given segment.Metric does not exceed max.
+func Threshold(metric segment.Metric, max float64) segment.Filter {
+	return FromPredicate(func(segment segment.Segment) bool {
+		return metric.Cost(segment) <= max
+	})
+}
@@ -0,0 +1,35 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/mblarer/conpass/segment"
+)
+
+// TopK returns a segment.Filter that keeps the k paths between the given
+// source ISD-AS and the destination ISD-AS with the lowest cost according to
+// a given segment.Metric, ordered by increasing cost. The paths are found by a
+// best-first search over the given segments, see segment.KShortestPaths.
+func TopK(k int, metric segment.Metric) segment.Filter {
+	return TopKWithOptions(k, metric, segment.EnumerationOptions{})
+}
+
+// TopKWithOptions is like TopK, but constructs the paths according to the
+// given segment.EnumerationOptions.
+func TopKWithOptions(k int, metric segment.Metric, opts segment.EnumerationOptions) segment.Filter {
+	return topK{k: k, metric: metric, opts: opts}
+}
+
+type topK struct {
+	k      int
+	metric segment.Metric
+	opts   segment.EnumerationOptions
+}
+
+func (tk topK) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return segset.WithSegments(segment.KShortestPaths(segset, tk.k, tk.metric, tk.opts))
+}
+
+func (tk topK) String() string {
+	return fmt.Sprintf("top %d", tk.k)
+}
@@ -0,0 +1,32 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+func TestTopKStage(t *testing.T) {
+	srcIA, _ := addr.IAFromString("19-ffaa:0:1303")
+	dstIA, _ := addr.IAFromString("17-ffaa:0:1108")
+	segments := []segment.Segment{
+		segment.FromString("19-ffaa:0:1303 1>1 19-ffaa:0:1302"),
+		segment.FromString("19-ffaa:0:1302 2>1 17-ffaa:0:1108"),
+		segment.FromString("19-ffaa:0:1302 3>1 17-ffaa:0:1101 2>3 17-ffaa:0:1108"),
+	}
+	segset := segment.SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA, Trace: segment.NewTrace()}
+	result := FromFilters(TopK(1, segment.HopCount)).Filter(segset)
+	want := segment.FromSegments(segments[0], segments[1])
+	if len(result.Segments) != 1 || segment.InterfaceFingerprint(result.Segments[0]) != segment.InterfaceFingerprint(want) {
+		t.Fatal("want:", want, "have:", result.Segments)
+	}
+	var report strings.Builder
+	if err := segset.Trace.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.String(), "top 1:") {
+		t.Error("want a stage named after the filter:\n", report.String())
+	}
+}
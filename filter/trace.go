@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mblarer/conpass/segment"
+)
+
+// Stage returns a segment.Filter that applies the given filter and records its
+// decisions under the given name in the segment.Trace of the SegmentSet, if
+// any. Stages that are recorded by the filter itself, e.g., by FromFilters,
+// are nested within the stage.
+func Stage(name string, filter segment.Filter) segment.Filter {
+	return stageFilter{name: name, filter: filter}
+}
+
+type stageFilter struct {
+	name   string
+	filter segment.Filter
+}
+
+func (sf stageFilter) Filter(segset segment.SegmentSet) segment.SegmentSet {
+	return sf.FilterWithContext(context.Background(), segment.NegotiationInfo{}, segset)
+}
+
+func (sf stageFilter) FilterWithContext(ctx context.Context, info segment.NegotiationInfo, segset segment.SegmentSet) segment.SegmentSet {
+	segset.Trace.Begin(sf.name)
+	result := segment.ApplyFilter(ctx, info, sf.filter, segset)
+	segset.Trace.End()
+	segset.Trace.Record(sf.name, segset, result)
+	return result
+}
+
+// traced applies a filter of a pipeline and records its decisions as a stage,
+// named after the filter if it implements fmt.Stringer, or after its position
+// in the pipeline otherwise. Stages and compositions record their own
+// decisions.
+func traced(ctx context.Context, info segment.NegotiationInfo, filter segment.Filter, position int, segset segment.SegmentSet) segment.SegmentSet {
+	if segset.Trace == nil {
+		return segment.ApplyFilter(ctx, info, filter, segset)
+	}
+	switch filter.(type) {
+	case stageFilter, filterComposition:
+		return segment.ApplyFilter(ctx, info, filter, segset)
+	}
+	name := fmt.Sprintf("stage %d", position+1)
+	if stringer, ok := filter.(fmt.Stringer); ok {
+		name = stringer.String()
+	}
+	return stageFilter{name: name, filter: filter}.FilterWithContext(ctx, info, segset)
+}
@@ -1,10 +1,13 @@
 package conpass
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"time"
 
+	"github.com/mblarer/conpass/filter"
 	"github.com/mblarer/conpass/segment"
 )
 
@@ -17,44 +20,143 @@ type Initiator struct {
 	// Filter is the segment filter according to which the Initiator gives
 	// consent to certain segments or combinations of segments.
 	Filter segment.Filter
-	// Verbose is a flag which makes the Initiator more verbose if true.
+	// Trace is a flag which makes the Initiator record the decisions of its
+	// filter and of the Responder in a segment.Trace, which is returned with
+	// the resulting SegmentSet.
+	Trace bool
+	// Aggregation combines the preference scores of both agents into the
+	// joint ranking by which the resulting SegmentSet is ordered, see
+	// segment.JointRanking. If it is nil, the order is not changed.
+	Aggregation segment.Aggregation
+	// Disclosure makes the Initiator disclose its segments progressively
+	// instead of all at once, see Disclosure. If it is nil, all segments are
+	// disclosed at once.
+	Disclosure *Disclosure
+	// Private makes the Initiator negotiate by a private set intersection of
+	// the segments that remain after its initial filtering with the segments
+	// of the Responder, such that neither agent learns the segments of the
+	// other agent beyond the intersection. The Responder must also be
+	// private. Disclosure, Aggregation and counter-offers are not supported
+	// in a private negotiation.
+	Private bool
+	// Options are application-specific options that are passed to the
+	// filter in the segment.NegotiationInfo.
+	Options map[string]string
+	// Verbose is a flag which makes the Initiator more verbose if true. It
+	// implies Trace and logs the trace report at the end of the negotiation.
 	Verbose bool
 }
 
 // NegotiateOver makes the Initiator negotiate consent over a given bytestream.
 // If the negotiation is successful, the method returns the set of segments
-// that have bilateral consent. Otherwise, an error is returned.
+// that have bilateral consent, together with the conditions of the consent,
+// see segment.SegmentSet.Conditions. Segments whose consent has expired are
+// dropped. Otherwise, an error is returned.
 func (agent Initiator) NegotiateOver(stream io.ReadWriter) (segment.SegmentSet, error) {
-	newsegset := agent.Filter.Filter(agent.InitialSegset)
+	return agent.NegotiateOverContext(context.Background(), stream)
+}
+
+// NegotiateOverContext is like NegotiateOver, but passes the given context
+// and a segment.NegotiationInfo to filters that implement
+// segment.ContextFilter.
+func (agent Initiator) NegotiateOverContext(ctx context.Context, stream io.ReadWriter) (segment.SegmentSet, error) {
+	segfilter := snapshot(agent.Filter)
+	info := negotiationInfo(stream, segment.RoleInitiator, agent.Options)
+	info.PeerIA = agent.InitialSegset.DstIA
+	segset := agent.InitialSegset
+	if agent.Trace || agent.Verbose {
+		segset.Trace = segment.NewTrace()
+	}
+	info.Round = 1
+	newsegset := segment.ApplyFilter(ctx, info, filter.Stage("initial", segfilter), segset)
 	if agent.Verbose {
-		log.Println(len(newsegset.Segments), "segments remaining after initial filtering:")
-		for _, segment := range newsegset.Segments {
-			fmt.Println(" ", segment)
+		log.Println(len(newsegset.Segments), "segments remaining after initial filtering")
+	}
+	if agent.Private {
+		shared, err := agent.intersectPrivately(stream, newsegset)
+		if err != nil {
+			return segment.SegmentSet{}, fmt.Errorf("private set intersection failed: %s", err.Error())
+		}
+		shared.Trace.Record("intersection", newsegset, shared)
+		if agent.Verbose {
+			log.Println(len(shared.Segments), "segments in the intersection")
+			shared.Trace.WriteReport(log.Writer())
 		}
+		return shared, nil
 	}
 	oldsegs := []segment.Segment{}
-	sentsegs, err := segment.WriteSegments(stream, newsegset.Segments, oldsegs, newsegset.SrcIA, newsegset.DstIA)
-	_, accsegs, _, _, err := segment.ReadSegments(stream, sentsegs)
-	if err != nil {
-		return segment.SegmentSet{}, fmt.Errorf("failed to decode server response: %s", err.Error())
+	disclosed := newsegset.WithSegments([]segment.Segment{})
+	accsegset := newsegset.WithSegments([]segment.Segment{})
+	exchanged := literals{}
+	literalsDisclosed := 0
+	peerPreferences := make(map[string]float64)
+	peerConditions := make(map[string]segment.Conditions)
+	for _, batch := range agent.Disclosure.batches(newsegset) {
+		request := segment.Message{
+			Segments:    batch,
+			Preferences: newsegset.Preferences,
+			Conditions:  newsegset.Conditions,
+			Progressive: agent.Disclosure != nil,
+			SrcIA:       newsegset.SrcIA,
+			DstIA:       newsegset.DstIA,
+		}
+		sentsegs, err := segment.WriteMessage(stream, request, oldsegs)
+		if err != nil {
+			return segment.SegmentSet{}, err
+		}
+		oldsegs = append(oldsegs, sentsegs...)
+		disclosed.Segments = append(disclosed.Segments, batch...)
+		literalsDisclosed += exchanged.disclose(batch)
+		response, recvsegs, err := segment.ReadMessage(stream, oldsegs)
+		if err != nil {
+			return segment.SegmentSet{}, fmt.Errorf("failed to decode server response: %s", err.Error())
+		}
+		oldsegs = append(oldsegs, recvsegs...)
+		exchanged.disclose(response.Proposals)
+		if agent.Verbose {
+			log.Println("the server replied with", len(response.Segments), "segments and", len(response.Proposals), "counter-offers")
+		}
+		// Counter-offers of the Responder are evaluated by the final
+		// filtering together with the accepted segments.
+		accsegset.Segments = append(accsegset.Segments, response.Segments...)
+		accsegset.Segments = append(accsegset.Segments, response.Proposals...)
+		for key, score := range response.Preferences {
+			peerPreferences[key] = score
+		}
+		for key, conditions := range response.Conditions {
+			peerConditions[key] = conditions
+		}
+		info.Round += 2
+		if agent.Disclosure != nil && agent.Disclosure.done(agent.tentative(ctx, info, segfilter, accsegset)) {
+			break
+		}
 	}
-	if agent.Verbose {
-		log.Println("the server replied with", len(accsegs), "segments:")
-		for _, segment := range accsegs {
-			fmt.Println(" ", segment)
+	if agent.Disclosure != nil {
+		closing := segment.Message{Close: true, SrcIA: newsegset.SrcIA, DstIA: newsegset.DstIA}
+		if _, err := segment.WriteMessage(stream, closing, oldsegs); err != nil {
+			return segment.SegmentSet{}, err
+		}
+		if agent.Verbose {
+			log.Println("disclosed", len(disclosed.Segments), "segments with", literalsDisclosed, "segment literals")
 		}
 	}
-	accsegset := segment.SegmentSet{
-		Segments: accsegs,
-		SrcIA:    agent.InitialSegset.SrcIA,
-		DstIA:    agent.InitialSegset.DstIA,
+	newsegset.Trace.Disclose(literalsDisclosed)
+	accsegset.Trace.Record("responder", disclosed, accsegset)
+	newsegset = segment.ApplyFilter(ctx, info, filter.Stage("final", segfilter), accsegset)
+	if agent.Aggregation != nil {
+		newsegset.Segments = segment.JointRanking(newsegset.Segments, newsegset.Preferences, peerPreferences, agent.Aggregation)
 	}
-	newsegset = agent.Filter.Filter(accsegset)
+	newsegset = withConditions(newsegset, peerConditions, time.Now())
 	if agent.Verbose {
-		log.Println(len(newsegset.Segments), "segments remaining after final filtering:")
-		for _, segment := range newsegset.Segments {
-			fmt.Println(" ", segment)
-		}
+		log.Println(len(newsegset.Segments), "segments remaining after final filtering")
+		newsegset.Trace.WriteReport(log.Writer())
 	}
 	return newsegset, nil
 }
+
+// tentative applies the final filtering to the segments that have been
+// agreed on so far, without recording it in the Trace.
+func (agent Initiator) tentative(ctx context.Context, info segment.NegotiationInfo, segfilter segment.Filter, accsegset segment.SegmentSet) segment.SegmentSet {
+	accsegset.Trace = nil
+	return segment.ApplyFilter(ctx, info, segfilter, accsegset)
+}
@@ -0,0 +1,73 @@
+package conpass
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"time"
+
+	"github.com/mblarer/conpass/segment"
+)
+
+// connectionStater is implemented by TLS connections, e.g., *tls.Conn.
+type connectionStater interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// negotiationInfo returns the segment.NegotiationInfo of a negotiation over a
+// given bytestream. The peer is authenticated if the stream is a TLS
+// connection on which the peer presented a certificate that was verified.
+func negotiationInfo(stream io.ReadWriter, role segment.Role, options map[string]string) segment.NegotiationInfo {
+	info := segment.NegotiationInfo{Role: role, Options: options}
+	switch s := stream.(type) {
+	case connectionStater:
+		info.Transport = "tls"
+		state := s.ConnectionState()
+		info.PeerCertificates = state.PeerCertificates
+		if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+			info.PeerIdentity = state.VerifiedChains[0][0].Subject.CommonName
+		}
+	case net.Conn:
+		info.Transport = s.RemoteAddr().Network()
+	}
+	return info
+}
+
+// snapshotter is implemented by filters that can change during a negotiation,
+// e.g., filter.Reloader.
+type snapshotter interface {
+	Snapshot() segment.Filter
+}
+
+// snapshot returns a filter that does not change during the negotiation, such
+// that a negotiation is completed with the filter with which it started.
+func snapshot(filter segment.Filter) segment.Filter {
+	if s, ok := filter.(snapshotter); ok {
+		return s.Snapshot()
+	}
+	return filter
+}
+
+// withConditions attaches the combined conditions of both agents to the
+// segments of a SegmentSet and drops the segments whose consent has expired at
+// the given time.
+func withConditions(segset segment.SegmentSet, peerConditions map[string]segment.Conditions, now time.Time) segment.SegmentSet {
+	conditions := make(map[string]segment.Conditions)
+	valid := make([]segment.Segment, 0, len(segset.Segments))
+	for _, seg := range segset.Segments {
+		combined := segment.ConditionsOf(segset.Conditions, seg).Combine(segment.ConditionsOf(peerConditions, seg))
+		if combined.Expired(now) {
+			continue
+		}
+		if !combined.IsZero() {
+			conditions[segment.InterfaceFingerprint(seg)] = combined
+		}
+		valid = append(valid, seg)
+	}
+	result := segset.WithSegments(valid)
+	result.Conditions = conditions
+	if len(valid) < len(segset.Segments) {
+		result.Trace.Record("expired", segset, result)
+	}
+	return result
+}
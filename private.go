@@ -0,0 +1,279 @@
+package conpass
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+// In a private negotiation, the agents compute the intersection of their
+// independently filtered segments with a Diffie-Hellman-style private set
+// intersection over the InterfaceFingerprints of the segments, such that
+// neither agent learns anything about the segments of the other agent beyond
+// the intersection and its size. The protocol is secure against
+// semi-honest agents:
+//
+//  1. The Initiator sends its hashed segments H(x), blinded with its secret
+//     scalar a, i.e., H(x)^a.
+//  2. The Responder returns these points blinded with its secret scalar b,
+//     i.e., H(x)^ab, in the same order, together with its own hashed and
+//     blinded segments H(y)^b.
+//  3. The Initiator blinds the points of the Responder, i.e., H(y)^ba, and
+//     intersects them with its own doubly blinded points. It returns the
+//     indices of the matching points of the Responder, such that the
+//     Responder also learns the intersection.
+//
+// The points are on the NIST P-256 curve and the lists of points are sorted,
+// such that their order does not reveal the order of the segments.
+
+// maxPrivateSegments is the maximum number of points or indices in a list.
+const maxPrivateSegments = 1 << 16
+
+var privateCurve = elliptic.P256()
+
+// blinder blinds points with a secret scalar.
+type blinder struct {
+	scalar []byte
+}
+
+func newBlinder() (blinder, error) {
+	scalar, _, _, err := elliptic.GenerateKey(privateCurve, rand.Reader)
+	if err != nil {
+		return blinder{}, err
+	}
+	return blinder{scalar: scalar}, nil
+}
+
+// blindHash hashes a segment to a point on the curve and blinds it.
+func (b blinder) blindHash(seg segment.Segment) []byte {
+	x, y := hashToCurve(segment.InterfaceFingerprint(seg))
+	x, y = privateCurve.ScalarMult(x, y, b.scalar)
+	return elliptic.MarshalCompressed(privateCurve, x, y)
+}
+
+// blind blinds a point that was received from the other agent.
+func (b blinder) blind(point []byte) ([]byte, error) {
+	x, y := elliptic.UnmarshalCompressed(privateCurve, point)
+	if x == nil {
+		return nil, errors.New("invalid curve point")
+	}
+	x, y = privateCurve.ScalarMult(x, y, b.scalar)
+	return elliptic.MarshalCompressed(privateCurve, x, y), nil
+}
+
+// hashToCurve maps a string to a point on the curve by hashing it to an x
+// coordinate until a y coordinate exists (try-and-increment).
+func hashToCurve(item string) (*big.Int, *big.Int) {
+	params := privateCurve.Params()
+	three := big.NewInt(3)
+	for counter := uint32(0); ; counter++ {
+		hash := sha256.New()
+		binary.Write(hash, binary.BigEndian, counter)
+		io.WriteString(hash, item)
+		x := new(big.Int).SetBytes(hash.Sum(nil))
+		x.Mod(x, params.P)
+		// y² = x³ - 3x + b
+		y2 := new(big.Int).Exp(x, three, params.P)
+		y2.Sub(y2, new(big.Int).Mul(three, x))
+		y2.Add(y2, params.B)
+		y2.Mod(y2, params.P)
+		if y := new(big.Int).ModSqrt(y2, params.P); y != nil {
+			return x, y
+		}
+	}
+}
+
+// privateItems returns the segments of a SegmentSet that are unique according
+// to their InterfaceFingerprint, together with their blinded hashes, sorted
+// by the blinded hashes.
+func privateItems(segset segment.SegmentSet, b blinder) ([]segment.Segment, [][]byte) {
+	items := segset.Dedup(segment.InterfaceFingerprint).Segments
+	points := make([][]byte, len(items))
+	for i, item := range items {
+		points[i] = b.blindHash(item)
+	}
+	sort.Sort(byPoint{items, points})
+	return items, points
+}
+
+type byPoint struct {
+	items  []segment.Segment
+	points [][]byte
+}
+
+func (p byPoint) Len() int           { return len(p.items) }
+func (p byPoint) Less(i, j int) bool { return bytes.Compare(p.points[i], p.points[j]) < 0 }
+func (p byPoint) Swap(i, j int) {
+	p.items[i], p.items[j] = p.items[j], p.items[i]
+	p.points[i], p.points[j] = p.points[j], p.points[i]
+}
+
+// intersectPrivately runs the private set intersection on the side of the
+// Initiator and returns the segments of the SegmentSet that are in the
+// intersection.
+func (agent Initiator) intersectPrivately(stream io.ReadWriter, segset segment.SegmentSet) (segment.SegmentSet, error) {
+	b, err := newBlinder()
+	if err != nil {
+		return segment.SegmentSet{}, err
+	}
+	items, points := privateItems(segset, b)
+	request := make([]byte, 16)
+	binary.BigEndian.PutUint64(request, uint64(segset.SrcIA.IAInt()))
+	binary.BigEndian.PutUint64(request[8:], uint64(segset.DstIA.IAInt()))
+	request = appendPoints(request, points)
+	if _, err := stream.Write(request); err != nil {
+		return segment.SegmentSet{}, err
+	}
+	doubled, err := readPoints(stream)
+	if err != nil {
+		return segment.SegmentSet{}, err
+	}
+	if len(doubled) != len(points) {
+		return segment.SegmentSet{}, errors.New("responder returned wrong number of points")
+	}
+	peerPoints, err := readPoints(stream)
+	if err != nil {
+		return segment.SegmentSet{}, err
+	}
+	peerIndices := make(map[string]int, len(peerPoints))
+	for i, point := range peerPoints {
+		point, err := b.blind(point)
+		if err != nil {
+			return segment.SegmentSet{}, err
+		}
+		peerIndices[string(point)] = i
+	}
+	shared := segment.SegmentSet{}
+	indices := make([]uint32, 0)
+	for i, point := range doubled {
+		if j, ok := peerIndices[string(point)]; ok {
+			shared.Segments = append(shared.Segments, items[i])
+			indices = append(indices, uint32(j))
+		}
+	}
+	if _, err := stream.Write(appendIndices(nil, indices)); err != nil {
+		return segment.SegmentSet{}, err
+	}
+	return segset.Intersect(shared, segment.InterfaceFingerprint), nil
+}
+
+// intersectPrivately runs the private set intersection on the side of the
+// Responder. The filter function maps the source and destination ISD-AS of
+// the Initiator to the segments of the Responder. It returns the filtered
+// segments and those that are in the intersection.
+func (agent Responder) intersectPrivately(stream io.ReadWriter, filter func(srcIA, dstIA addr.IA) segment.SegmentSet) (segment.SegmentSet, segment.SegmentSet, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(stream, header); err != nil {
+		return segment.SegmentSet{}, segment.SegmentSet{}, err
+	}
+	srcIA := addr.IAInt(binary.BigEndian.Uint64(header)).IA()
+	dstIA := addr.IAInt(binary.BigEndian.Uint64(header[8:])).IA()
+	peerPoints, err := readPoints(stream)
+	if err != nil {
+		return segment.SegmentSet{}, segment.SegmentSet{}, err
+	}
+	segset := filter(srcIA, dstIA)
+	b, err := newBlinder()
+	if err != nil {
+		return segment.SegmentSet{}, segment.SegmentSet{}, err
+	}
+	doubled := make([][]byte, len(peerPoints))
+	for i, point := range peerPoints {
+		if doubled[i], err = b.blind(point); err != nil {
+			return segment.SegmentSet{}, segment.SegmentSet{}, err
+		}
+	}
+	items, points := privateItems(segset, b)
+	if _, err := stream.Write(appendPoints(appendPoints(nil, doubled), points)); err != nil {
+		return segment.SegmentSet{}, segment.SegmentSet{}, err
+	}
+	indices, err := readIndices(stream)
+	if err != nil {
+		return segment.SegmentSet{}, segment.SegmentSet{}, err
+	}
+	shared := segment.SegmentSet{}
+	for _, index := range indices {
+		if int(index) >= len(items) {
+			return segment.SegmentSet{}, segment.SegmentSet{}, errors.New("initiator returned invalid index")
+		}
+		shared.Segments = append(shared.Segments, items[index])
+	}
+	return segset, segset.Intersect(shared, segment.InterfaceFingerprint), nil
+}
+
+func appendPoints(buffer []byte, points [][]byte) []byte {
+	buffer = appendLength(buffer, len(points))
+	for _, point := range points {
+		buffer = append(buffer, point...)
+	}
+	return buffer
+}
+
+func readPoints(stream io.Reader) ([][]byte, error) {
+	length, err := readLength(stream)
+	if err != nil {
+		return nil, err
+	}
+	pointlen := (privateCurve.Params().BitSize+7)/8 + 1
+	buffer := make([]byte, length*pointlen)
+	if _, err := io.ReadFull(stream, buffer); err != nil {
+		return nil, err
+	}
+	points := make([][]byte, length)
+	for i := range points {
+		points[i] = buffer[i*pointlen : (i+1)*pointlen]
+	}
+	return points, nil
+}
+
+func appendIndices(buffer []byte, indices []uint32) []byte {
+	buffer = appendLength(buffer, len(indices))
+	for _, index := range indices {
+		buffer = append(buffer, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(buffer[len(buffer)-4:], index)
+	}
+	return buffer
+}
+
+func readIndices(stream io.Reader) ([]uint32, error) {
+	length, err := readLength(stream)
+	if err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, length*4)
+	if _, err := io.ReadFull(stream, buffer); err != nil {
+		return nil, err
+	}
+	indices := make([]uint32, length)
+	for i := range indices {
+		indices[i] = binary.BigEndian.Uint32(buffer[i*4:])
+	}
+	return indices, nil
+}
+
+func appendLength(buffer []byte, length int) []byte {
+	buffer = append(buffer, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buffer[len(buffer)-4:], uint32(length))
+	return buffer
+}
+
+func readLength(stream io.Reader) (int, error) {
+	buffer := make([]byte, 4)
+	if _, err := io.ReadFull(stream, buffer); err != nil {
+		return 0, err
+	}
+	length := int(binary.BigEndian.Uint32(buffer))
+	if length > maxPrivateSegments {
+		return 0, errors.New("too many segments")
+	}
+	return length, nil
+}
@@ -1,11 +1,14 @@
 package conpass
 
 import (
-	"fmt"
+	"context"
 	"io"
 	"log"
+	"time"
 
+	"github.com/mblarer/conpass/filter"
 	"github.com/mblarer/conpass/segment"
+	"github.com/scionproto/scion/go/lib/addr"
 )
 
 // Responder represents a CONPASS agent in the responder role.
@@ -13,7 +16,40 @@ type Responder struct {
 	// Filter is the segment filter according to which the Responder gives
 	// consent to certain segments or combinations of segments.
 	Filter segment.Filter
-	// Verbose is a flag which makes the Responder more verbose if true.
+	// Bidirectional is a flag which makes the Responder additionally filter
+	// the reversed segments from its own perspective, i.e., for its traffic
+	// towards the Initiator. Only segments that are accepted in both
+	// directions are consented to.
+	Bidirectional bool
+	// SegmentStore is a set of segments that are known to the Responder,
+	// e.g., down-segments to itself that the Initiator may not have. If the
+	// Responder rejects every segment of a request, it combines the request
+	// with these segments, filters them, and attaches the resulting segments
+	// as counter-offers for the Initiator to evaluate. In a private
+	// negotiation, see Private, the Responder negotiates with the segments of
+	// the SegmentStore instead of the requested segments.
+	SegmentStore []segment.Segment
+	// Trace is a flag which makes the Responder record the decisions of its
+	// filter in a segment.Trace, which is returned with the resulting
+	// SegmentSet. In a progressive negotiation, the Trace contains the
+	// decisions of the last round, in which all requested segments are
+	// filtered, and the number of segments that were sent in all rounds.
+	Trace bool
+	// Aggregation combines the preference scores of both agents into the
+	// joint ranking by which the resulting SegmentSet is ordered, see
+	// segment.JointRanking. If it is nil, the order is not changed.
+	Aggregation segment.Aggregation
+	// Private makes the Responder negotiate by a private set intersection of
+	// the segments of its SegmentStore that are accepted by its filter with
+	// the segments of the Initiator, see Initiator.Private. The filter needs
+	// to construct the paths between the source and destination ISD-AS of
+	// the Initiator, e.g., with filter.SrcDstPathEnumerator.
+	Private bool
+	// Options are application-specific options that are passed to the
+	// filter in the segment.NegotiationInfo.
+	Options map[string]string
+	// Verbose is a flag which makes the Responder more verbose if true. It
+	// implies Trace and logs the trace report at the end of the negotiation.
 	Verbose bool
 }
 
@@ -21,27 +57,153 @@ type Responder struct {
 // If the negotiation is successful, the method returns the set of segments
 // that have bilateral consent. Otherwise, an error is returned.
 func (agent Responder) NegotiateOver(stream io.ReadWriter) (segment.SegmentSet, error) {
-	segsin, accsegs, srcIA, dstIA, err := segment.ReadSegments(stream, []segment.Segment{})
-	if err != nil {
-		return segment.SegmentSet{}, err
+	return agent.NegotiateOverContext(context.Background(), stream)
+}
+
+// NegotiateOverContext is like NegotiateOver, but passes the given context
+// and a segment.NegotiationInfo to filters that implement
+// segment.ContextFilter.
+func (agent Responder) NegotiateOverContext(ctx context.Context, stream io.ReadWriter) (segment.SegmentSet, error) {
+	segfilter := snapshot(agent.Filter)
+	if agent.Bidirectional {
+		segfilter = filter.Bidirectional(segfilter)
 	}
-	if agent.Verbose {
-		log.Println("request contains", len(segsin), "segments:")
-		for _, segment := range segsin {
-			fmt.Println(" ", segment)
+	info := negotiationInfo(stream, segment.RoleResponder, agent.Options)
+	if agent.Private {
+		return agent.negotiatePrivately(ctx, stream, info, segfilter, agent.newTrace())
+	}
+	oldsegs := []segment.Segment{}
+	requested := []segment.Segment{}
+	responded := make(map[string]bool)
+	exchanged := literals{}
+	disclosed := 0
+	peerPreferences := make(map[string]float64)
+	peerConditions := make(map[string]segment.Conditions)
+	var segsetout segment.SegmentSet
+	for {
+		request, segsin, err := segment.ReadMessage(stream, oldsegs)
+		if err != nil {
+			return segment.SegmentSet{}, err
+		}
+		if request.Close {
+			break
+		}
+		oldsegs = append(oldsegs, segsin...)
+		srcIA, dstIA := request.SrcIA, request.DstIA
+		if agent.Verbose {
+			log.Println("request contains", len(segsin), "segments")
+		}
+		// In a progressive negotiation, the segments of all requests so far
+		// are filtered together, but only newly accepted segments are sent.
+		// The trace is recorded anew in every round, such that it contains
+		// the decisions of the last filtering of all requested segments.
+		requested = append(requested, request.Segments...)
+		exchanged.disclose(request.Segments)
+		for key, score := range request.Preferences {
+			peerPreferences[key] = score
+		}
+		for key, conditions := range request.Conditions {
+			peerConditions[key] = conditions
+		}
+		segsetin := segment.SegmentSet{
+			Segments: requested,
+			SrcIA:    srcIA,
+			DstIA:    dstIA,
+			Trace:    agent.newTrace(),
+		}
+		info.PeerIA = srcIA
+		info.Round += 2
+		segsetout = segment.ApplyFilter(ctx, info, filter.Stage("responder", segfilter), segsetin)
+		if agent.Aggregation != nil {
+			segsetout.Segments = segment.JointRanking(segsetout.Segments, peerPreferences, segsetout.Preferences, agent.Aggregation)
+		}
+		response := segment.Message{
+			Segments:    make([]segment.Segment, 0),
+			Preferences: segsetout.Preferences,
+			Conditions:  segsetout.Conditions,
+			SrcIA:       srcIA,
+			DstIA:       dstIA,
+		}
+		for _, seg := range segsetout.Segments {
+			if key := segment.InterfaceFingerprint(seg); !responded[key] {
+				responded[key] = true
+				response.Segments = append(response.Segments, seg)
+			}
+		}
+		if len(segsetout.Segments) == 0 && len(agent.SegmentStore) > 0 {
+			offered := agent.counterOffers(ctx, info, segfilter, segsetin)
+			response.Proposals, response.Preferences = offered.Segments, offered.Preferences
+			response.Conditions = offered.Conditions
+		}
+		if agent.Verbose {
+			log.Println("responding with", len(response.Segments), "segments and", len(response.Proposals), "counter-offers")
+		}
+		sentsegs, err := segment.WriteMessage(stream, response, oldsegs)
+		if err != nil {
+			return segment.SegmentSet{}, err
+		}
+		oldsegs = append(oldsegs, sentsegs...)
+		disclosed += exchanged.disclose(response.Segments) + exchanged.disclose(response.Proposals)
+		if !request.Progressive {
+			break
 		}
 	}
-	segsetout := agent.Filter.Filter(segment.SegmentSet{
-		Segments: accsegs,
-		SrcIA:    srcIA,
-		DstIA:    dstIA,
+	segsetout.Trace.Disclose(disclosed)
+	segsetout = withConditions(segsetout, peerConditions, time.Now())
+	if agent.Verbose {
+		segsetout.Trace.WriteReport(log.Writer())
+	}
+	return segsetout, nil
+}
+
+// newTrace returns a new segment.Trace if tracing is enabled, or nil.
+func (agent Responder) newTrace() *segment.Trace {
+	if agent.Trace || agent.Verbose {
+		return segment.NewTrace()
+	}
+	return nil
+}
+
+// negotiatePrivately filters the segments of the SegmentStore and intersects
+// them privately with the segments of the Initiator.
+func (agent Responder) negotiatePrivately(ctx context.Context, stream io.ReadWriter, info segment.NegotiationInfo, segfilter segment.Filter, trace *segment.Trace) (segment.SegmentSet, error) {
+	segsetout, shared, err := agent.intersectPrivately(stream, func(srcIA, dstIA addr.IA) segment.SegmentSet {
+		segsetin := segment.SegmentSet{
+			Segments: agent.SegmentStore,
+			SrcIA:    srcIA,
+			DstIA:    dstIA,
+			Trace:    trace,
+		}
+		info.PeerIA = srcIA
+		info.Round = 2
+		return segment.ApplyFilter(ctx, info, filter.Stage("responder", segfilter), segsetin)
 	})
+	if err != nil {
+		return segment.SegmentSet{}, err
+	}
+	shared.Trace.Record("intersection", segsetout, shared)
 	if agent.Verbose {
-		log.Println("responding with", len(segsetout.Segments), "segments:")
-		for _, segment := range segsetout.Segments {
-			fmt.Println(" ", segment)
+		log.Println(len(shared.Segments), "segments in the intersection")
+		shared.Trace.WriteReport(log.Writer())
+	}
+	return shared, nil
+}
+
+// counterOffers filters the segments of a request together with the segments
+// of the SegmentStore and returns the accepted segments that are not part of
+// the request.
+func (agent Responder) counterOffers(ctx context.Context, info segment.NegotiationInfo, segfilter segment.Filter, segsetin segment.SegmentSet) segment.SegmentSet {
+	requested := make(map[string]bool)
+	for _, seg := range segsetin.Segments {
+		requested[seg.Fingerprint()] = true
+	}
+	segments := append(append([]segment.Segment{}, segsetin.Segments...), agent.SegmentStore...)
+	offered := segment.ApplyFilter(ctx, info, filter.Stage("counter-offer", segfilter), segsetin.WithSegments(segments))
+	proposals := make([]segment.Segment, 0)
+	for _, seg := range offered.Segments {
+		if !requested[seg.Fingerprint()] {
+			proposals = append(proposals, seg)
 		}
 	}
-	segment.WriteSegments(stream, segsetout.Segments, segsin, srcIA, dstIA)
-	return segsetout, nil
+	return offered.WithSegments(proposals)
 }
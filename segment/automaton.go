@@ -0,0 +1,156 @@
+package segment
+
+import (
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/common"
+)
+
+// Hop is the traversal of an AS on a path. The ingress interface of the first
+// hop and the egress interface of the last hop of a path are 0.
+type Hop struct {
+	// IA is the ISD-AS address of the traversed AS.
+	IA addr.IA
+	// Ingress is the interface over which the path enters the AS.
+	Ingress common.IFIDType
+	// Egress is the interface over which the path leaves the AS.
+	Egress common.IFIDType
+}
+
+// HopAutomaton is a finite automaton that reads the hops of a path and
+// decides whether to accept it. States are represented as strings, such that
+// identical states can be recognized and evaluated only once.
+type HopAutomaton interface {
+	// Start returns the initial state of the automaton.
+	Start() string
+	// Step returns the state of the automaton after reading a hop. If ok is
+	// false, the automaton cannot accept the path anymore.
+	Step(state string, hop Hop) (next string, ok bool)
+	// Accepts reports whether the automaton accepts in the given state.
+	Accepts(state string) bool
+}
+
+// EnumerateMatchingPaths lazily enumerates the end-to-end paths of a
+// SegmentSet that are accepted by a HopAutomaton and calls yield for each of
+// them, until yield returns false. The automaton is evaluated on the segments
+// of the SegmentSet while the paths are constructed (product construction),
+// such that partial paths are pruned as soon as the automaton rejects them.
+// The paths are constructed according to the given EnumerationOptions and in
+// the same order as by EnumeratePathsWithOptions.
+func EnumerateMatchingPaths(ss SegmentSet, opts EnumerationOptions, automaton HopAutomaton, yield func(Segment) bool) {
+	candidates := opts.candidates(ss.Segments, ss.SrcIA, ss.DstIA)
+	pe := productEnumerator{
+		dstIA:     ss.DstIA,
+		graph:     NewGraph(ss.WithSegments(candidates)),
+		automaton: automaton,
+		memo:      make(map[productKey]bool),
+	}
+	maxlen := opts.maxSegments()
+	if ss.SrcIA == ss.DstIA {
+		return
+	}
+	visited := make(map[addr.IA]bool)
+	pe.walk(nil, visited, maxlen, ss.SrcIA, 0, automaton.Start(), func(seglist []Segment) bool {
+		path := seglist[0]
+		if len(seglist) > 1 {
+			path = FromSegments(seglist...)
+		}
+		if opts.LoopFree && HasLoop(path) {
+			return true
+		}
+		return yield(path)
+	})
+}
+
+type productKey struct {
+	srcIA   addr.IA
+	ingress common.IFIDType
+	state   string
+	maxlen  int
+}
+
+// productEnumerator enumerates the accepted segment lists from an ISD-AS
+// address and automaton state to the destination ISD-AS and memoizes from
+// which intermediate ISD-AS addresses and automaton states the destination is
+// reachable with an accepted path.
+type productEnumerator struct {
+	dstIA     addr.IA
+	graph     *Graph
+	automaton HopAutomaton
+	memo      map[productKey]bool
+}
+
+// reachable reports whether the destination ISD-AS can be reached from srcIA
+// with at most maxlen segments, such that the automaton accepts the path.
+func (pe productEnumerator) reachable(maxlen int, srcIA addr.IA, ingress common.IFIDType, state string) bool {
+	if srcIA == pe.dstIA {
+		final, ok := pe.automaton.Step(state, Hop{IA: srcIA, Ingress: ingress})
+		return ok && pe.automaton.Accepts(final)
+	} else if maxlen <= 0 {
+		return false
+	}
+	key := productKey{srcIA: srcIA, ingress: ingress, state: state, maxlen: maxlen}
+	if reachable, ok := pe.memo[key]; ok {
+		return reachable
+	}
+	reachable := false
+	for _, srcToMidSegment := range pe.graph.Outgoing(srcIA) {
+		next, nextIngress, ok := pe.traverse(state, ingress, srcToMidSegment)
+		if ok && pe.reachable(maxlen-1, srcToMidSegment.DstIA(), nextIngress, next) {
+			reachable = true
+			break
+		}
+	}
+	pe.memo[key] = reachable
+	return reachable
+}
+
+// walk extends a segment list that ends at srcIA by at most maxlen segments
+// towards the destination ISD-AS, without returning to a visited ISD-AS, and
+// calls yield for each segment list that reaches the destination and is
+// accepted by the automaton. It returns false as soon as yield returns false.
+func (pe productEnumerator) walk(seglist []Segment, visited map[addr.IA]bool, maxlen int, srcIA addr.IA, ingress common.IFIDType, state string, yield func([]Segment) bool) bool {
+	if !pe.reachable(maxlen, srcIA, ingress, state) {
+		return true
+	} else if srcIA == pe.dstIA {
+		return yield(seglist)
+	}
+	visited[srcIA] = true
+	defer delete(visited, srcIA)
+	for _, srcToMidSegment := range pe.graph.Outgoing(srcIA) {
+		midIA := srcToMidSegment.DstIA()
+		if visited[midIA] {
+			continue
+		}
+		next, nextIngress, ok := pe.traverse(state, ingress, srcToMidSegment)
+		if !ok {
+			continue
+		}
+		if !pe.walk(append(seglist, srcToMidSegment), visited, maxlen-1, midIA, nextIngress, next, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// traverse feeds the hops of a segment into the automaton, given the ingress
+// interface over which the path entered the source ISD-AS of the segment. It
+// returns the resulting state and the ingress interface at the destination
+// ISD-AS of the segment, whose hop is only complete with the next segment.
+func (pe productEnumerator) traverse(state string, ingress common.IFIDType, segment Segment) (string, common.IFIDType, bool) {
+	interfaces := segment.PathInterfaces()
+	if len(interfaces) < 2 {
+		return state, ingress, false
+	}
+	hops := make([]Hop, 0, len(interfaces)/2)
+	hops = append(hops, Hop{IA: interfaces[0].IA, Ingress: ingress, Egress: interfaces[0].ID})
+	for i := 1; i < len(interfaces)-1; i += 2 {
+		hops = append(hops, Hop{IA: interfaces[i].IA, Ingress: interfaces[i].ID, Egress: interfaces[i+1].ID})
+	}
+	for _, hop := range hops {
+		var ok bool
+		if state, ok = pe.automaton.Step(state, hop); !ok {
+			return state, 0, false
+		}
+	}
+	return state, interfaces[len(interfaces)-1].ID, true
+}
@@ -0,0 +1,78 @@
+package segment
+
+import "time"
+
+// Conditions are the conditions under which an agent consents to a segment,
+// e.g., "only until 18:00" or "up to 100 Mbit/s". The zero value of a field
+// means that there is no condition on it, hence the zero value of Conditions
+// is unconditional consent. Conditions are not enforced by the agents, except
+// that the agents drop segments whose consent has expired from the result of
+// a negotiation.
+type Conditions struct {
+	// NotBefore is the time from which on the consent is valid.
+	NotBefore time.Time
+	// NotAfter is the time until which the consent is valid.
+	NotAfter time.Time
+	// Bandwidth is the maximum bandwidth in bits per second.
+	Bandwidth uint64
+	// Volume is the maximum traffic volume in bytes.
+	Volume uint64
+	// Priority is the priority class in which traffic is sent, where 0 is
+	// the default class.
+	Priority uint8
+}
+
+// IsZero reports whether the Conditions do not restrict the consent.
+func (c Conditions) IsZero() bool {
+	return c == Conditions{}
+}
+
+// Expired reports whether the consent is no longer valid at the given time.
+func (c Conditions) Expired(now time.Time) bool {
+	return !c.NotAfter.IsZero() && now.After(c.NotAfter)
+}
+
+// Valid reports whether the consent is valid at the given time.
+func (c Conditions) Valid(now time.Time) bool {
+	return !c.Expired(now) && !now.Before(c.NotBefore)
+}
+
+// Combine returns the Conditions that satisfy both c and other, i.e., the
+// shorter validity interval, the lower bandwidth and volume, and the higher
+// priority class.
+func (c Conditions) Combine(other Conditions) Conditions {
+	if other.NotBefore.After(c.NotBefore) {
+		c.NotBefore = other.NotBefore
+	}
+	if !other.NotAfter.IsZero() && (c.NotAfter.IsZero() || other.NotAfter.Before(c.NotAfter)) {
+		c.NotAfter = other.NotAfter
+	}
+	c.Bandwidth = minLimit(c.Bandwidth, other.Bandwidth)
+	c.Volume = minLimit(c.Volume, other.Volume)
+	if other.Priority > c.Priority {
+		c.Priority = other.Priority
+	}
+	return c
+}
+
+// minLimit returns the lower of two limits, where 0 means unlimited.
+func minLimit(a, b uint64) uint64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// ConditionsOf returns the Conditions of a segment according to the given
+// conditions, which are keyed by InterfaceFingerprint. The Conditions of a
+// composition are combined with those of its subsegments, since consent to a
+// path requires consent to all of its segments.
+func ConditionsOf(conditions map[string]Conditions, segment Segment) Conditions {
+	combined := conditions[InterfaceFingerprint(segment)]
+	if composition, ok := segment.(Composition); ok {
+		for _, subseg := range composition.Segments {
+			combined = combined.Combine(ConditionsOf(conditions, subseg))
+		}
+	}
+	return combined
+}
@@ -0,0 +1,72 @@
+package segment
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestConditionsOf(t *testing.T) {
+	ab := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")
+	bc := FromString("1-ffaa:0:2 2>1 1-ffaa:0:3")
+	noon := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	conditions := map[string]Conditions{
+		InterfaceFingerprint(ab): {NotAfter: noon.Add(6 * time.Hour), Bandwidth: 100e6},
+		InterfaceFingerprint(bc): {NotBefore: noon, NotAfter: noon.Add(time.Hour), Volume: 1e9, Priority: 1},
+	}
+	want := Conditions{NotBefore: noon, NotAfter: noon.Add(time.Hour), Bandwidth: 100e6, Volume: 1e9, Priority: 1}
+	if have := ConditionsOf(conditions, FromSegments(ab, bc)); have != want {
+		t.Errorf("want: %+v, have: %+v", want, have)
+	}
+	if ConditionsOf(conditions, FromString("1-ffaa:0:3 2>1 1-ffaa:0:4")) != (Conditions{}) {
+		t.Error("segment without conditions is conditional")
+	}
+	for _, test := range []struct {
+		now            time.Time
+		valid, expired bool
+	}{
+		{noon.Add(-time.Minute), false, false},
+		{noon.Add(time.Minute), true, false},
+		{noon.Add(2 * time.Hour), false, true},
+	} {
+		if want.Valid(test.now) != test.valid || want.Expired(test.now) != test.expired {
+			t.Errorf("at %s: want valid %t and expired %t", test.now, test.valid, test.expired)
+		}
+	}
+}
+
+func TestConditionsAreTransmitted(t *testing.T) {
+	ab := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")
+	bc := FromString("1-ffaa:0:2 2>1 1-ffaa:0:3")
+	conditions := Conditions{
+		NotBefore: time.Unix(1622548800, 0),
+		NotAfter:  time.Unix(1622570400, 0),
+		Bandwidth: 100e6,
+		Volume:    1e9,
+		Priority:  3,
+	}
+	message := Message{
+		Segments:    []Segment{ab, bc},
+		Preferences: map[string]float64{InterfaceFingerprint(ab): 1},
+		Conditions:  map[string]Conditions{InterfaceFingerprint(ab): conditions},
+	}
+	var buffer bytes.Buffer
+	if _, err := WriteMessage(&buffer, message, []Segment{}); err != nil {
+		t.Fatal(err)
+	}
+	received, _, err := ReadMessage(&buffer, []Segment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received.Conditions) != 1 {
+		t.Fatal("want conditions for 1 segment, have:", received.Conditions)
+	}
+	have := received.Conditions[InterfaceFingerprint(ab)]
+	if !have.NotBefore.Equal(conditions.NotBefore) || !have.NotAfter.Equal(conditions.NotAfter) ||
+		have.Bandwidth != conditions.Bandwidth || have.Volume != conditions.Volume || have.Priority != conditions.Priority {
+		t.Errorf("want: %+v, have: %+v", conditions, have)
+	}
+	if received.Preferences[InterfaceFingerprint(ab)] != 1 {
+		t.Error("preference is lost next to conditions")
+	}
+}
@@ -19,6 +19,23 @@ const (
 	segAcceptedMask  uint8 = 1 << 1
 	segAcceptedFalse uint8 = 0 << 1
 	segAcceptedTrue  uint8 = 1 << 1
+	// The type of a literal (up/core/down/peering) is encoded in bits 2-4.
+	segLitTypeShift uint8 = 2
+	segLitTypeMask  uint8 = 7 << segLitTypeShift
+	// The direction of a literal is encoded in bits 5-6.
+	segLitDirShift uint8 = 5
+	segLitDirMask  uint8 = 3 << segLitDirShift
+	// Whether a segment is a proposal (counter-offer) is the most significant
+	// bit. Proposals are never accepted segments.
+	segProposalMask  uint8 = 1 << 7
+	segProposalFalse uint8 = 0 << 7
+	segProposalTrue  uint8 = 1 << 7
+)
+
+const (
+	// The first byte of the message header contains the message flags.
+	msgProgressiveMask uint8 = 1 << 0
+	msgCloseMask       uint8 = 1 << 1
 )
 
 // ReadSegments reads from the given bytestream and decodes the bytes received from
@@ -29,18 +46,59 @@ const (
 // the source and destination ASes. If the decoding failed, an error is
 // returned instead.
 func ReadSegments(stream io.Reader, oldsegs []Segment) ([]Segment, []Segment, addr.IA, addr.IA, error) {
+	message, newsegs, err := ReadMessage(stream, oldsegs)
+	return newsegs, message.Segments, message.SrcIA, message.DstIA, err
+}
+
+// Message is the content of a CONPASS message.
+type Message struct {
+	// Segments are the accepted segments.
+	Segments []Segment
+	// Proposals are segments that are offered to the other agent for its
+	// consent instead of being accepted, e.g., the counter-offers of a
+	// Responder. Proposals that are also accepted are not transmitted.
+	Proposals []Segment
+	// Preferences are the preference scores of the sending agent for the
+	// transmitted segments, see SegmentSet.Preferences.
+	Preferences map[string]float64
+	// Conditions are the conditions under which the sending agent consents
+	// to the transmitted segments, see SegmentSet.Conditions.
+	Conditions map[string]Conditions
+	// Progressive indicates that the Initiator discloses its segments
+	// progressively, i.e., that the Responder must expect further requests
+	// after the response to this request.
+	Progressive bool
+	// Close indicates that the Initiator ends a progressive negotiation. The
+	// message contains no segments and is not answered.
+	Close bool
+	// SrcIA is the source ISD-AS address of the segments.
+	SrcIA addr.IA
+	// DstIA is the destination ISD-AS address of the segments.
+	DstIA addr.IA
+}
+
+// ReadMessage is like ReadSegments, but returns the decoded Message and all
+// segments that were transmitted. Proposals and subsegments are contained in
+// the transmitted segments, but not in Message.Segments.
+func ReadMessage(stream io.Reader, oldsegs []Segment) (Message, []Segment, error) {
+	message := Message{
+		Preferences: make(map[string]float64),
+		Conditions:  make(map[string]Conditions),
+	}
 	header := make([]byte, 24)
 	n, err := stream.Read(header)
 	if n < 24 || (err != nil && err != io.EOF) {
-		return nil, nil, addr.IA{}, addr.IA{}, err
+		return message, nil, err
 	}
+	message.Progressive = header[0]&msgProgressiveMask != 0
+	message.Close = header[0]&msgCloseMask != 0
 	hdrlen := int(header[1])
 	numsegs := int(binary.BigEndian.Uint16(header[2:]))
 	msglen := int(binary.BigEndian.Uint32(header[4:]))
-	srcIA := addr.IAInt(binary.BigEndian.Uint64(header[8:])).IA()
-	dstIA := addr.IAInt(binary.BigEndian.Uint64(header[16:])).IA()
+	message.SrcIA = addr.IAInt(binary.BigEndian.Uint64(header[8:])).IA()
+	message.DstIA = addr.IAInt(binary.BigEndian.Uint64(header[16:])).IA()
 	if msglen < 24 || msglen > (1<<22) { // 4 MiB is the limit
-		return nil, nil, srcIA, dstIA, errors.New("bad message size")
+		return message, nil, errors.New("bad message size")
 	}
 
 	msglen -= 24 // the size of the header was included in msglen
@@ -52,22 +110,29 @@ func ReadSegments(stream io.Reader, oldsegs []Segment) ([]Segment, []Segment, ad
 		err = e
 	}
 	if err != nil && err != io.EOF {
-		return nil, nil, srcIA, dstIA, err
+		return message, nil, err
 	}
 	bytes = bytes[hdrlen-24:] // skip per-message options (included in hdrlen)
 
 	newsegs := make([]Segment, numsegs)
-	accsegs := make([]Segment, 0)
+	message.Segments = make([]Segment, 0)
+	message.Proposals = make([]Segment, 0)
 	for i := 0; i < numsegs; i++ {
 		flags := bytes[0]
 		segtype := flags & segTypeMask
 		accepted := segAcceptedTrue == (flags & segAcceptedMask)
+		proposal := segProposalTrue == (flags & segProposalMask)
 		seglen := int(bytes[1])
 		optlen := int(binary.BigEndian.Uint16(bytes[2:]))
 
+		var options []byte
 		switch segtype {
 		case segTypeLiteral:
-			newsegs[i] = FromInterfaces(decodeInterfaces(bytes[4:], seglen)...)
+			literal := FromInterfaces(decodeInterfaces(bytes[4:], seglen)...).(Literal)
+			literal.Type = Type((flags & segLitTypeMask) >> segLitTypeShift)
+			literal.Dir = Direction((flags & segLitDirMask) >> segLitDirShift)
+			newsegs[i] = literal
+			options = bytes[4+seglen*16 : 4+seglen*16+optlen]
 			bytes = bytes[4+seglen*16+optlen:]
 		case segTypeComposition:
 			subsegs := make([]Segment, seglen)
@@ -80,17 +145,22 @@ func ReadSegments(stream io.Reader, oldsegs []Segment) ([]Segment, []Segment, ad
 					subsegs[j] = newsegs[int(id)-len(oldsegs)]
 				default:
 					err := errors.New("subsegment id is greater/equal to segment id")
-					return nil, nil, srcIA, dstIA, err
+					return message, nil, err
 				}
 			}
 			newsegs[i] = FromSegments(subsegs...)
+			options = bytes[4+seglen*2 : 4+seglen*2+optlen]
 			bytes = bytes[4+seglen*2+optlen:]
 		}
-		if accepted {
-			accsegs = append(accsegs, newsegs[i])
+		decodeOptions(options, newsegs[i], &message)
+		switch {
+		case accepted:
+			message.Segments = append(message.Segments, newsegs[i])
+		case proposal:
+			message.Proposals = append(message.Proposals, newsegs[i])
 		}
 	}
-	return newsegs, accsegs, srcIA, dstIA, nil
+	return message, newsegs, nil
 }
 
 func decodeInterfaces(bytes []byte, seglen int) []snet.PathInterface {
@@ -111,7 +181,12 @@ func decodeInterfaces(bytes []byte, seglen int) []snet.PathInterface {
 // account the ``old'' set of segments, which is already known to both agents.
 // The function returns the encoded segments in the order of transmission.
 func WriteSegments(stream io.Writer, newsegs, oldsegs []Segment, srcIA, dstIA addr.IA) ([]Segment, error) {
-	bytes, sentsegs := EncodeSegments(newsegs, oldsegs, srcIA, dstIA)
+	return WriteMessage(stream, Message{Segments: newsegs, SrcIA: srcIA, DstIA: dstIA}, oldsegs)
+}
+
+// WriteMessage is like WriteSegments, but encodes a Message.
+func WriteMessage(stream io.Writer, message Message, oldsegs []Segment) ([]Segment, error) {
+	bytes, sentsegs := EncodeMessage(message, oldsegs)
 	_, err := stream.Write(bytes)
 	if err != nil {
 		return nil, err
@@ -124,11 +199,23 @@ func WriteSegments(stream io.Writer, newsegs, oldsegs []Segment, srcIA, dstIA ad
 // which is already known to both agents.  The function returns the byte
 // sequence and the encoded segments in the order of transmission.
 func EncodeSegments(newsegs, oldsegs []Segment, srcIA, dstIA addr.IA) ([]byte, []Segment) {
+	return EncodeMessage(Message{Segments: newsegs, SrcIA: srcIA, DstIA: dstIA}, oldsegs)
+}
+
+// EncodeMessage is like EncodeSegments, but encodes a Message. The proposals
+// are encoded after the accepted segments.
+func EncodeMessage(message Message, oldsegs []Segment) ([]byte, []Segment) {
 	hdrlen := 24
 	allbytes := make([]byte, hdrlen)
+	if message.Progressive {
+		allbytes[0] |= msgProgressiveMask
+	}
+	if message.Close {
+		allbytes[0] |= msgCloseMask
+	}
 	allbytes[1] = uint8(hdrlen)
-	binary.BigEndian.PutUint64(allbytes[8:], uint64(srcIA.IAInt()))
-	binary.BigEndian.PutUint64(allbytes[16:], uint64(dstIA.IAInt()))
+	binary.BigEndian.PutUint64(allbytes[8:], uint64(message.SrcIA.IAInt()))
+	binary.BigEndian.PutUint64(allbytes[16:], uint64(message.DstIA.IAInt()))
 
 	segidx := make(map[string]int)
 	for idx, seg := range oldsegs {
@@ -136,8 +223,13 @@ func EncodeSegments(newsegs, oldsegs []Segment, srcIA, dstIA addr.IA) ([]byte, [
 	}
 	currentIdx := len(oldsegs)
 	sentsegs := make([]Segment, 0)
+	encode := func(segment Segment, flags uint8) {
+		options := encodeOptions(segment, message)
+		allbytes = append(allbytes, encodeSegment(segment, flags, options, segidx)...)
+		sentsegs = append(sentsegs, segment)
+	}
 
-	for _, newseg := range newsegs {
+	for _, newseg := range message.Segments {
 		// encode (unaccepted) subsegments
 		subsegs := recursiveSubsegments(newseg)
 		for _, subseg := range subsegs {
@@ -145,9 +237,7 @@ func EncodeSegments(newsegs, oldsegs []Segment, srcIA, dstIA addr.IA) ([]byte, [
 			if _, ok := segidx[fprint]; !ok { // not seen before
 				segidx[fprint] = currentIdx
 				currentIdx++
-				accepted := false
-				allbytes = append(allbytes, encodeSegment(subseg, accepted, segidx)...)
-				sentsegs = append(sentsegs, subseg)
+				encode(subseg, segAcceptedFalse)
 			}
 		}
 		// encode (accepted) segment
@@ -155,39 +245,56 @@ func EncodeSegments(newsegs, oldsegs []Segment, srcIA, dstIA addr.IA) ([]byte, [
 		if idx, ok := segidx[fprint]; !ok { // not seen before
 			segidx[fprint] = currentIdx
 			currentIdx++
-			accepted := true
-			allbytes = append(allbytes, encodeSegment(newseg, accepted, segidx)...)
-			sentsegs = append(sentsegs, newseg)
-		} else { // seen before
+			encode(newseg, segAcceptedTrue)
+		} else { // seen before, possibly earlier in this message
+			known := oldsegs[idx:]
+			if idx >= len(oldsegs) {
+				known = sentsegs[idx-len(oldsegs):]
+			}
 			currentIdx++
-			accepted := true
-			allbytes = append(allbytes, encodeSegment(FromSegments(oldsegs[idx]), accepted, segidx)...)
-			sentsegs = append(sentsegs, FromSegments(oldsegs[idx]))
+			encode(FromSegments(known[0]), segAcceptedTrue)
 		}
 	}
 
+	for _, proposal := range message.Proposals {
+		if _, ok := segidx[proposal.Fingerprint()]; ok { // known or accepted
+			continue
+		}
+		// encode (unaccepted) subsegments
+		for _, subseg := range recursiveSubsegments(proposal) {
+			fprint := subseg.Fingerprint()
+			if _, ok := segidx[fprint]; !ok { // not seen before
+				segidx[fprint] = currentIdx
+				currentIdx++
+				encode(subseg, segAcceptedFalse)
+			}
+		}
+		// encode (proposed) segment
+		segidx[proposal.Fingerprint()] = currentIdx
+		currentIdx++
+		encode(proposal, segAcceptedFalse|segProposalTrue)
+	}
+
 	numsegs := uint16(currentIdx - len(oldsegs))
 	binary.BigEndian.PutUint16(allbytes[2:], numsegs)
 	binary.BigEndian.PutUint32(allbytes[4:], uint32(len(allbytes)))
 	return allbytes, sentsegs
 }
 
-func encodeSegment(segment Segment, accepted bool, segidx map[string]int) []byte {
-	var flags uint8
-	var seglen, optlen int
-	if accepted {
-		flags = segAcceptedTrue
-	} else {
-		flags = segAcceptedFalse
-	}
+func encodeSegment(segment Segment, flags uint8, options []byte, segidx map[string]int) []byte {
+	var seglen int
+	optlen := len(options)
 	var bytes []byte
 
 	switch s := segment.(type) {
 	case Literal:
 		flags |= segTypeLiteral
+		flags |= (uint8(s.Type) << segLitTypeShift) & segLitTypeMask
+		flags |= (uint8(s.Dir) << segLitDirShift) & segLitDirMask
 		seglen = len(s.Interfaces)
 		bytes = make([]byte, 4+seglen*16+optlen)
 		encodeInterfaces(bytes[4:], s.Interfaces)
+		copy(bytes[4+seglen*16:], options)
 	case Composition:
 		flags |= segTypeComposition
 		seglen = len(s.Segments)
@@ -195,6 +302,7 @@ func encodeSegment(segment Segment, accepted bool, segidx map[string]int) []byte
 		for i, subseg := range s.Segments {
 			binary.BigEndian.PutUint16(bytes[4+i*2:], uint16(segidx[subseg.Fingerprint()]))
 		}
+		copy(bytes[4+seglen*2:], options)
 	}
 
 	bytes[0] = flags
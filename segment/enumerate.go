@@ -2,68 +2,318 @@ package segment
 
 import (
 	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/snet"
 )
 
+const defaultMaxSegments = 3 // SCION-specific
+
+// EnumerationOptions control how end-to-end paths are constructed from a set
+// of segments. The zero value enumerates all paths that consist of up to
+// three segments joined end-to-end at their endpoint ISD-AS addresses.
+type EnumerationOptions struct {
+	// MaxSegments is the maximum number of segments that are joined into
+	// an end-to-end path. If it is zero, a maximum of three segments is used.
+	MaxSegments int
+	// Shortcuts enables the construction of shortcut paths, i.e., an up- and
+	// a down-segment that cross the same AS are cut at that AS and joined.
+	Shortcuts bool
+	// Peering enables the construction of peering paths, i.e., a peering
+	// segment that ends with a peering link is joined with a peering segment
+	// that starts with the same peering link.
+	Peering bool
+	// OnPath enables the truncation of segments that cross the source or the
+	// destination ISD-AS, such that they start or end there.
+	OnPath bool
+	// LoopFree enables the rejection of paths that cross an AS or use an
+	// interface more than once, e.g., because two segments cross the same
+	// transit AS. Such paths are not allowed in SCION.
+	LoopFree bool
+}
+
+func (opts EnumerationOptions) maxSegments() int {
+	if opts.MaxSegments == 0 {
+		return defaultMaxSegments
+	}
+	return opts.MaxSegments
+}
+
 // SrcDstPaths enumerates all possible end-to-end segments between a source and
 // destination ISD-AS pair from a given set of segments. For constant-bounded
 // segment length, the runtime complexity is linear in the number of
 // enumeratable segments starting at the source ISD-AS.
 func SrcDstPaths(segments []Segment, srcIA, dstIA addr.IA) []Segment {
-	maxSegLen := 3 // SCION-specific
-	buckets := createSegmentBuckets(segments)
-	seglists := recursiveSrcDstSeglists(maxSegLen, srcIA, dstIA, buckets)
-	flattened := flattenSeglists(seglists)
-	return flattened
+	return SrcDstPathsWithOptions(segments, srcIA, dstIA, EnumerationOptions{})
 }
 
-func createSegmentBuckets(segments []Segment) map[addr.IA][]Segment {
-	buckets := make(map[addr.IA][]Segment, len(segments))
-	for _, segment := range segments {
-		srcIA, dstIA := segment.SrcIA(), segment.DstIA()
-		if srcIA == dstIA { // cyclic
+// SrcDstPathsWithOptions enumerates all possible end-to-end segments between a
+// source and destination ISD-AS pair from a given set of segments, according
+// to the given EnumerationOptions.
+func SrcDstPathsWithOptions(segments []Segment, srcIA, dstIA addr.IA, opts EnumerationOptions) []Segment {
+	paths := make([]Segment, 0)
+	ss := SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	EnumeratePathsWithOptions(ss, opts, func(path Segment) bool {
+		paths = append(paths, path)
+		return true
+	})
+	return paths
+}
+
+// EnumeratePaths lazily enumerates the end-to-end paths of a SegmentSet and
+// calls yield for each of them, until yield returns false. In contrast to
+// SrcDstPaths, the paths are constructed one by one, such that the
+// enumeration can be terminated early.
+func EnumeratePaths(ss SegmentSet, yield func(Segment) bool) {
+	EnumeratePathsWithOptions(ss, EnumerationOptions{}, yield)
+}
+
+// EnumeratePathsWithOptions lazily enumerates the end-to-end paths of a
+// SegmentSet according to the given EnumerationOptions and calls yield for
+// each of them, until yield returns false. Sub-paths from intermediate ISD-AS
+// addresses to the destination are computed on demand and memoized, such that
+// they are computed only once and then shared between all paths that end with
+// them.
+func EnumeratePathsWithOptions(ss SegmentSet, opts EnumerationOptions, yield func(Segment) bool) {
+	candidates := opts.candidates(ss.Segments, ss.SrcIA, ss.DstIA)
+	e := enumerator{
+		dstIA: ss.DstIA,
+		graph: NewGraph(ss.WithSegments(candidates)),
+		memo:  make(map[suffixKey]*suffixes),
+	}
+	if ss.SrcIA == ss.DstIA {
+		return
+	}
+	srcToDst := e.suffixes(opts.maxSegments(), ss.SrcIA)
+	for i := 0; ; i++ {
+		seglist, ok := srcToDst.at(i)
+		if !ok {
+			return
+		}
+		path := seglist[0]
+		if len(seglist) > 1 {
+			path = FromSegments(seglist...)
+		}
+		if opts.LoopFree && HasLoop(path) {
 			continue
 		}
-		buckets[srcIA] = append(buckets[srcIA], segment)
+		if !yield(path) {
+			return
+		}
 	}
-	return buckets
 }
 
-func recursiveSrcDstSeglists(maxlen int, srcIA, dstIA addr.IA, buckets map[addr.IA][]Segment) [][]Segment {
-	if srcIA == dstIA {
-		return [][]Segment{{}} // outer list contains one empty segment list
-	} else if maxlen <= 0 {
-		return [][]Segment{} // outer list is empty
-	}
-	srcToDstSeglists := make([][]Segment, 0)
-	for _, srcToMidSegment := range buckets[srcIA] {
-		midIA := srcToMidSegment.DstIA()
-		midToDstSeglists := recursiveSrcDstSeglists(maxlen-1, midIA, dstIA, buckets)
-		for _, midToDstSeglist := range midToDstSeglists {
-			cyclic := false
-			for _, seg := range midToDstSeglist {
-				if srcIA == seg.DstIA() {
-					cyclic = true
-				}
+// HasLoop reports whether a segment crosses an AS or uses an interface more
+// than once. An AS is only allowed to occur in consecutive interfaces of the
+// segment, i.e., as ingress and egress interface of the same hop.
+func HasLoop(segment Segment) bool {
+	interfaces := segment.PathInterfaces()
+	seenIAs := make(map[addr.IA]bool, len(interfaces))
+	seenIfaces := make(map[snet.PathInterface]bool, len(interfaces))
+	for i, iface := range interfaces {
+		if seenIfaces[iface] {
+			return true
+		}
+		seenIfaces[iface] = true
+		if i > 0 && interfaces[i-1].IA == iface.IA {
+			continue
+		}
+		if seenIAs[iface.IA] {
+			return true
+		}
+		seenIAs[iface.IA] = true
+	}
+	return false
+}
+
+// candidates returns the given segments together with the additional
+// segments that are constructed according to the options.
+func (opts EnumerationOptions) candidates(segments []Segment, srcIA, dstIA addr.IA) []Segment {
+	if !opts.Shortcuts && !opts.Peering && !opts.OnPath {
+		return segments
+	}
+	literals := make([]Literal, 0)
+	for _, segment := range segments {
+		if literal, ok := segment.(Literal); ok {
+			literals = append(literals, literal)
+		}
+	}
+	candidates := append([]Segment(nil), segments...)
+	if opts.OnPath {
+		candidates = append(candidates, onPathSegments(literals, srcIA, dstIA)...)
+	}
+	if opts.Shortcuts {
+		candidates = append(candidates, shortcutSegments(literals)...)
+	}
+	if opts.Peering {
+		candidates = append(candidates, peeringSegments(literals)...)
+	}
+	return SegmentSet{Segments: candidates}.Dedup(Segment.Fingerprint).Segments
+}
+
+// onPathSegments truncates literals that cross the source or destination
+// ISD-AS, such that they start at the source or end at the destination.
+func onPathSegments(literals []Literal, srcIA, dstIA addr.IA) []Segment {
+	segments := make([]Segment, 0)
+	for _, literal := range literals {
+		first := literal.transitIndex(srcIA)
+		last := literal.transitIndex(dstIA)
+		if first >= 0 {
+			segments = append(segments, literal.slice(first+1, len(literal.Interfaces)))
+		}
+		if last >= 0 {
+			segments = append(segments, literal.slice(0, last+1))
+		}
+		if first >= 0 && last >= 0 && first < last {
+			segments = append(segments, literal.slice(first+1, last+1))
+		}
+	}
+	return segments
+}
+
+// shortcutSegments joins the beginning of an up-segment with the end of a
+// down-segment if both cross the same AS.
+func shortcutSegments(literals []Literal) []Segment {
+	segments := make([]Segment, 0)
+	for _, up := range literals {
+		if up.Type != TypeUp && up.Type != TypeUnspecified {
+			continue
+		}
+		for _, down := range literals {
+			if down.Type != TypeDown && down.Type != TypeUnspecified {
+				continue
 			}
-			if !cyclic {
-				srcToDstSeglist := append([]Segment{srcToMidSegment}, midToDstSeglist...)
-				srcToDstSeglists = append(srcToDstSeglists, srcToDstSeglist)
+			if up.Fingerprint() == down.Fingerprint() {
+				continue
+			}
+			for i := 1; i < len(up.Interfaces)-1; i += 2 {
+				j := down.transitIndex(up.Interfaces[i].IA)
+				if j < 0 {
+					continue
+				}
+				segments = append(segments, FromSegments(
+					up.slice(0, i+1),
+					down.slice(j+1, len(down.Interfaces)),
+				))
 			}
 		}
 	}
-	return srcToDstSeglists
+	return segments
 }
 
-func flattenSeglists(seglists [][]Segment) []Segment {
+// peeringSegments joins peering segments that end with a peering link with
+// peering segments that start with the same peering link, i.e., the last two
+// interfaces of the first segment are equal to the first two interfaces of
+// the second segment, see SplitPath.
+func peeringSegments(literals []Literal) []Segment {
 	segments := make([]Segment, 0)
-	for _, seglist := range seglists {
-		switch len(seglist) {
-		case 0: // Skip if segment list is empty
-		case 1:
-			segments = append(segments, seglist[0])
-		default:
-			segments = append(segments, FromSegments(seglist...))
+	for _, up := range literals {
+		if up.Type != TypePeering || up.Dir == DirConstruction {
+			continue
+		}
+		for _, down := range literals {
+			if down.Type != TypePeering || down.Dir == DirAgainstConstruction {
+				continue
+			}
+			if !sharePeeringLink(up, down) {
+				continue
+			}
+			if len(down.Interfaces) == 2 {
+				segments = append(segments, up)
+			} else {
+				segments = append(segments, FromSegments(up, down.slice(2, len(down.Interfaces))))
+			}
 		}
 	}
 	return segments
 }
+
+// sharePeeringLink reports whether the first segment ends with the link with
+// which the second segment starts.
+func sharePeeringLink(up, down Literal) bool {
+	n := len(up.Interfaces)
+	if n < 2 || len(down.Interfaces) < 2 {
+		return false
+	}
+	return up.Interfaces[n-2] == down.Interfaces[0] && up.Interfaces[n-1] == down.Interfaces[1]
+}
+
+type suffixKey struct {
+	srcIA  addr.IA
+	maxlen int
+}
+
+// enumerator memoizes the segment lists from intermediate ISD-AS addresses to
+// the destination ISD-AS.
+type enumerator struct {
+	dstIA addr.IA
+	graph *Graph
+	memo  map[suffixKey]*suffixes
+}
+
+// suffixes is a list of segment lists from an ISD-AS address to the
+// destination ISD-AS that is computed on demand. Computed segment lists are
+// kept, such that they can be shared between all paths that end with them.
+type suffixes struct {
+	seglists [][]Segment
+	next     func() ([]Segment, bool)
+}
+
+// at returns the i-th segment list, which is computed if necessary, or false
+// if there are no more than i segment lists.
+func (s *suffixes) at(i int) ([]Segment, bool) {
+	for len(s.seglists) <= i && s.next != nil {
+		seglist, ok := s.next()
+		if !ok {
+			s.next = nil
+			break
+		}
+		s.seglists = append(s.seglists, seglist)
+	}
+	if i < len(s.seglists) {
+		return s.seglists[i], true
+	}
+	return nil, false
+}
+
+// suffixes returns the segment lists from srcIA to the destination ISD-AS with
+// at most maxlen segments that do not return to srcIA.
+func (e enumerator) suffixes(maxlen int, srcIA addr.IA) *suffixes {
+	if srcIA == e.dstIA {
+		return &suffixes{seglists: [][]Segment{{}}} // one empty segment list
+	} else if maxlen <= 0 {
+		return &suffixes{} // no segment list
+	}
+	key := suffixKey{srcIA: srcIA, maxlen: maxlen}
+	if srcToDst, ok := e.memo[key]; ok {
+		return srcToDst
+	}
+	outgoing := e.graph.Outgoing(srcIA)
+	k, j := 0, 0
+	srcToDst := &suffixes{}
+	srcToDst.next = func() ([]Segment, bool) {
+		for k < len(outgoing) {
+			srcToMidSegment := outgoing[k]
+			midToDstSeglist, ok := e.suffixes(maxlen-1, srcToMidSegment.DstIA()).at(j)
+			if !ok {
+				k, j = k+1, 0
+				continue
+			}
+			j++
+			if !cyclic(srcIA, midToDstSeglist) {
+				return append([]Segment{srcToMidSegment}, midToDstSeglist...), true
+			}
+		}
+		return nil, false
+	}
+	e.memo[key] = srcToDst
+	return srcToDst
+}
+
+// cyclic reports whether a segment list returns to the given ISD-AS.
+func cyclic(srcIA addr.IA, seglist []Segment) bool {
+	for _, seg := range seglist {
+		if srcIA == seg.DstIA() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+package segment
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+func TestEnumerationOptions(t *testing.T) {
+	up := FromString("1-ffaa:0:111 1>1 1-ffaa:0:110 2>1 1-ffaa:0:100").(Literal)
+	up.Type = TypeUp
+	down := FromString("1-ffaa:0:100 2>1 1-ffaa:0:110 3>1 1-ffaa:0:112").(Literal)
+	down.Type = TypeDown
+	peerUp := FromString("1-ffaa:0:111 1>1 1-ffaa:0:110 5>7 2-ffaa:0:210").(Literal)
+	peerUp.Type, peerUp.Dir = TypePeering, DirAgainstConstruction
+	peerDown := FromString("1-ffaa:0:110 5>7 2-ffaa:0:210 1>1 2-ffaa:0:211").(Literal)
+	peerDown.Type, peerDown.Dir = TypePeering, DirConstruction
+	otherPeerDown := FromString("1-ffaa:0:120 6>7 2-ffaa:0:210 1>1 2-ffaa:0:211").(Literal)
+	otherPeerDown.Type, otherPeerDown.Dir = TypePeering, DirConstruction
+	chain := []Segment{
+		FromString("1-ffaa:0:1 1>1 1-ffaa:0:2"),
+		FromString("1-ffaa:0:2 2>1 1-ffaa:0:3"),
+		FromString("1-ffaa:0:3 2>1 1-ffaa:0:4"),
+		FromString("1-ffaa:0:4 2>1 1-ffaa:0:5"),
+	}
+
+	tests := []struct {
+		name     string
+		segments []Segment
+		src, dst string
+		opts     EnumerationOptions
+		want     []Segment
+	}{
+		{"no shortcut", []Segment{up, down}, "1-ffaa:0:111", "1-ffaa:0:112",
+			EnumerationOptions{}, []Segment{
+				FromString("1-ffaa:0:111 1>1 1-ffaa:0:110 2>1 1-ffaa:0:100 2>1 1-ffaa:0:110 3>1 1-ffaa:0:112"),
+			}},
+		{"shortcut", []Segment{up, down}, "1-ffaa:0:111", "1-ffaa:0:112",
+			EnumerationOptions{Shortcuts: true}, []Segment{
+				FromString("1-ffaa:0:111 1>1 1-ffaa:0:110 2>1 1-ffaa:0:100 2>1 1-ffaa:0:110 3>1 1-ffaa:0:112"),
+				FromString("1-ffaa:0:111 1>1 1-ffaa:0:110 3>1 1-ffaa:0:112"),
+			}},
+		{"no on-path", []Segment{up}, "1-ffaa:0:111", "1-ffaa:0:110",
+			EnumerationOptions{}, nil},
+		{"on-path", []Segment{up}, "1-ffaa:0:111", "1-ffaa:0:110",
+			EnumerationOptions{OnPath: true}, []Segment{
+				FromString("1-ffaa:0:111 1>1 1-ffaa:0:110"),
+			}},
+		{"no peering", []Segment{peerUp, peerDown}, "1-ffaa:0:111", "2-ffaa:0:211",
+			EnumerationOptions{}, nil},
+		{"peering", []Segment{peerUp, peerDown}, "1-ffaa:0:111", "2-ffaa:0:211",
+			EnumerationOptions{Peering: true}, []Segment{
+				FromString("1-ffaa:0:111 1>1 1-ffaa:0:110 5>7 2-ffaa:0:210 1>1 2-ffaa:0:211"),
+			}},
+		{"unrelated peering", []Segment{peerUp, otherPeerDown}, "1-ffaa:0:111", "2-ffaa:0:211",
+			EnumerationOptions{Peering: true}, nil},
+		{"default max segments", chain, "1-ffaa:0:1", "1-ffaa:0:5",
+			EnumerationOptions{}, nil},
+		{"increased max segments", chain, "1-ffaa:0:1", "1-ffaa:0:5",
+			EnumerationOptions{MaxSegments: 4}, []Segment{
+				FromString("1-ffaa:0:1 1>1 1-ffaa:0:2 2>1 1-ffaa:0:3 2>1 1-ffaa:0:4 2>1 1-ffaa:0:5"),
+			}},
+	}
+	for _, test := range tests {
+		srcIA, _ := addr.IAFromString(test.src)
+		dstIA, _ := addr.IAFromString(test.dst)
+		have := SrcDstPathsWithOptions(test.segments, srcIA, dstIA, test.opts)
+		assertPaths(test.name, have, test.want, t)
+	}
+}
+
+func TestEnumerationLoopFree(t *testing.T) {
+	// The AS 1-ffaa:0:10 is crossed by two segments, the AS 1-ffaa:0:20 is
+	// crossed by a segment and is the endpoint of another one.
+	segments := []Segment{
+		FromString("1-ffaa:0:1 1>1 1-ffaa:0:10 2>1 1-ffaa:0:2"),
+		FromString("1-ffaa:0:2 2>3 1-ffaa:0:10 4>1 1-ffaa:0:3"),
+		FromString("1-ffaa:0:2 3>1 1-ffaa:0:4"),
+		FromString("1-ffaa:0:4 2>1 1-ffaa:0:20 2>1 1-ffaa:0:3"),
+		FromString("1-ffaa:0:3 2>1 1-ffaa:0:5"),
+		FromString("1-ffaa:0:3 3>1 1-ffaa:0:20 3>1 1-ffaa:0:5"),
+	}
+	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
+	dstIA, _ := addr.IAFromString("1-ffaa:0:5")
+
+	all := SrcDstPathsWithOptions(segments, srcIA, dstIA, EnumerationOptions{MaxSegments: 4})
+	if len(all) != 4 {
+		t.Fatal("want 4 paths without loop detection, have:", len(all))
+	}
+	have := SrcDstPathsWithOptions(segments, srcIA, dstIA, EnumerationOptions{MaxSegments: 4, LoopFree: true})
+	want := []Segment{
+		FromString("1-ffaa:0:1 1>1 1-ffaa:0:10 2>1 1-ffaa:0:2 3>1 1-ffaa:0:4 2>1 1-ffaa:0:20 2>1 1-ffaa:0:3 2>1 1-ffaa:0:5"),
+	}
+	assertPaths("loop-free", have, want, t)
+	for _, path := range all {
+		if HasLoop(path) == (InterfaceFingerprint(path) == InterfaceFingerprint(want[0])) {
+			t.Error("wrong loop detection:", path)
+		}
+	}
+}
+
+func TestEnumeratePathsEarlyTermination(t *testing.T) {
+	segments := []Segment{
+		FromString("1-ffaa:0:1 1>1 1-ffaa:0:2"),
+		FromString("1-ffaa:0:1 2>2 1-ffaa:0:2"),
+		FromString("1-ffaa:0:2 3>1 1-ffaa:0:3"),
+		FromString("1-ffaa:0:2 4>2 1-ffaa:0:3"),
+	}
+	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
+	dstIA, _ := addr.IAFromString("1-ffaa:0:3")
+	ss := SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+
+	all := make([]Segment, 0)
+	EnumeratePaths(ss, func(path Segment) bool {
+		all = append(all, path)
+		return true
+	})
+	assertPaths("all paths", all, ss.EnumeratePaths(), t)
+	if len(all) != 4 {
+		t.Error("want 4 paths, have:", len(all))
+	}
+	calls := 0
+	EnumeratePaths(ss, func(path Segment) bool {
+		calls++
+		return calls < 3
+	})
+	if calls != 3 {
+		t.Error("enumeration did not stop, want 3 calls, have:", calls)
+	}
+}
+
+func TestEnumerationMemoizesSuffixesLazily(t *testing.T) {
+	segments := []Segment{
+		FromString("1-ffaa:0:1 1>1 1-ffaa:0:2"),
+		FromString("1-ffaa:0:1 2>2 1-ffaa:0:2"),
+		FromString("1-ffaa:0:2 3>1 1-ffaa:0:3"),
+		FromString("1-ffaa:0:2 4>2 1-ffaa:0:3"),
+	}
+	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
+	midIA, _ := addr.IAFromString("1-ffaa:0:2")
+	dstIA, _ := addr.IAFromString("1-ffaa:0:3")
+	ss := SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+	e := enumerator{dstIA: dstIA, graph: NewGraph(ss), memo: make(map[suffixKey]*suffixes)}
+
+	srcToDst := e.suffixes(defaultMaxSegments, srcIA)
+	if _, ok := srcToDst.at(0); !ok {
+		t.Fatal("want a first path, have none")
+	}
+	midToDst := e.memo[suffixKey{srcIA: midIA, maxlen: defaultMaxSegments - 1}]
+	if midToDst == nil || len(midToDst.seglists) != 1 {
+		t.Fatal("want exactly one suffix computed for the first path")
+	}
+	for i := 1; i < 4; i++ {
+		if _, ok := srcToDst.at(i); !ok {
+			t.Fatal("want 4 paths, have:", i)
+		}
+	}
+	if _, ok := srcToDst.at(4); ok {
+		t.Error("want 4 paths, have more")
+	}
+	if len(midToDst.seglists) != 2 {
+		t.Error("want the 2 suffixes to be computed once, have:", len(midToDst.seglists))
+	}
+}
+
+func TestKShortestPaths(t *testing.T) {
+	segments := []Segment{
+		FromString("1-ffaa:0:1 1>1 1-ffaa:0:2"),
+		FromString("1-ffaa:0:1 2>1 1-ffaa:0:10 2>2 1-ffaa:0:2"),
+		FromString("1-ffaa:0:2 3>1 1-ffaa:0:3"),
+		FromString("1-ffaa:0:2 4>1 1-ffaa:0:20 2>1 1-ffaa:0:21 2>2 1-ffaa:0:3"),
+		FromString("1-ffaa:0:1 3>1 1-ffaa:0:30 2>1 1-ffaa:0:31 2>1 1-ffaa:0:32 2>1 1-ffaa:0:33 2>3 1-ffaa:0:3"),
+	}
+	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
+	dstIA, _ := addr.IAFromString("1-ffaa:0:3")
+	ss := SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+
+	have := KShortestPaths(ss, 3, HopCount, EnumerationOptions{})
+	want := []Segment{
+		FromSegments(segments[0], segments[2]),
+		FromSegments(segments[1], segments[2]),
+		FromSegments(segments[0], segments[3]),
+	}
+	assertPaths("3 shortest paths", have, want, t)
+	if all := KShortestPaths(ss, 10, HopCount, EnumerationOptions{}); len(all) != 5 {
+		t.Error("want 5 paths, have:", len(all))
+	}
+}
+
+func TestKShortestPathsSharedPrefixes(t *testing.T) {
+	// There are three paths to 1-ffaa:0:2, each of which is extended by two
+	// segments to the destination.
+	segments := []Segment{
+		FromString("1-ffaa:0:1 1>1 1-ffaa:0:2"),
+		FromString("1-ffaa:0:1 2>1 1-ffaa:0:10 2>2 1-ffaa:0:2"),
+		FromString("1-ffaa:0:1 3>1 1-ffaa:0:11 2>1 1-ffaa:0:12 2>3 1-ffaa:0:2"),
+		FromString("1-ffaa:0:2 4>1 1-ffaa:0:3"),
+		FromString("1-ffaa:0:2 5>1 1-ffaa:0:20 2>2 1-ffaa:0:3"),
+	}
+	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
+	dstIA, _ := addr.IAFromString("1-ffaa:0:3")
+	ss := SegmentSet{Segments: segments, SrcIA: srcIA, DstIA: dstIA}
+
+	want := []Segment{
+		FromSegments(segments[0], segments[3]),
+		FromSegments(segments[0], segments[4]),
+		FromSegments(segments[1], segments[3]),
+		FromSegments(segments[1], segments[4]),
+		FromSegments(segments[2], segments[3]),
+		FromSegments(segments[2], segments[4]),
+	}
+	for k := 1; k <= len(want); k++ {
+		have := KShortestPaths(ss, k, HopCount, EnumerationOptions{})
+		assertPaths(fmt.Sprintf("%d shortest paths", k), have, want[:k], t)
+	}
+}
+
+func assertPaths(name string, have, want []Segment, t *testing.T) {
+	if len(have) != len(want) {
+		t.Errorf("%s: want %d paths, have %d: %v", name, len(want), len(have), have)
+		return
+	}
+	for i := range have {
+		if InterfaceFingerprint(have[i]) != InterfaceFingerprint(want[i]) {
+			t.Errorf("%s: want: %s, have: %s", name, want[i], have[i])
+		}
+	}
+}
@@ -1,5 +1,12 @@
 package segment
 
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
 // Filter is the interface that CONPASS agents need to implement in order to
 // apply their consent logic. In order to allow for all kinds of consent logic,
 // a filter is simply a function from one SegmentSet to another SegmentSet. In
@@ -10,3 +17,68 @@ type Filter interface {
 	// Filter maps the original to the resulting (accepted) SegmentSet.
 	Filter(SegmentSet) SegmentSet
 }
+
+// ContextFilter is implemented by filters whose consent logic depends on the
+// negotiation in which they are applied, e.g., on the identity of the peer.
+// CONPASS agents and the combinators of the filter package call
+// FilterWithContext instead of Filter on filters that implement it.
+type ContextFilter interface {
+	// FilterWithContext maps the original to the resulting (accepted)
+	// SegmentSet in the given negotiation.
+	FilterWithContext(context.Context, NegotiationInfo, SegmentSet) SegmentSet
+}
+
+// Role is the role of a CONPASS agent in a negotiation.
+type Role int
+
+const (
+	RoleUnspecified Role = iota
+	RoleInitiator
+	RoleResponder
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleInitiator:
+		return "initiator"
+	case RoleResponder:
+		return "responder"
+	}
+	return "unspecified"
+}
+
+// NegotiationInfo describes the negotiation in which a filter is applied. The
+// zero value describes a filter that is applied outside of a negotiation.
+type NegotiationInfo struct {
+	// PeerIA is the ISD-AS address of the peer, i.e., the destination for the
+	// Initiator and the source for the Responder.
+	PeerIA addr.IA
+	// PeerIdentity is the authenticated identity of the peer, i.e., the
+	// common name of the leaf certificate of its verified TLS certificate
+	// chain. It is empty if the peer is not authenticated, in particular if
+	// the peer presented a certificate that was not verified.
+	PeerIdentity string
+	// PeerCertificates is the certificate chain that the peer presented, if
+	// any, starting with its leaf certificate. The chain is not necessarily
+	// verified, see PeerIdentity.
+	PeerCertificates []*x509.Certificate
+	// Transport is the name of the transport over which the negotiation
+	// takes place, e.g., "tls" or "tcp". It is empty if it is unknown.
+	Transport string
+	// Round is the number of the filter application within the negotiation,
+	// starting at 1 for the initial filtering of the Initiator.
+	Round int
+	// Role is the role of the agent that applies the filter.
+	Role Role
+	// Options are application-specific options of the negotiation.
+	Options map[string]string
+}
+
+// ApplyFilter applies a filter in the given negotiation. If the filter
+// implements ContextFilter, FilterWithContext is called, otherwise Filter.
+func ApplyFilter(ctx context.Context, info NegotiationInfo, filter Filter, segset SegmentSet) SegmentSet {
+	if cf, ok := filter.(ContextFilter); ok {
+		return cf.FilterWithContext(ctx, info, segset)
+	}
+	return filter.Filter(segset)
+}
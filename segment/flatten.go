@@ -0,0 +1,35 @@
+package segment
+
+// Literals returns the sequence of segment literals of which a segment
+// consists. Nested compositions are resolved recursively. Segments that are
+// neither a Literal nor a Composition are converted into a single Literal.
+func Literals(segment Segment) []Literal {
+	switch s := segment.(type) {
+	case Literal:
+		return []Literal{s}
+	case Composition:
+		literals := make([]Literal, 0, len(s.Segments))
+		for _, subseg := range s.Segments {
+			literals = append(literals, Literals(subseg)...)
+		}
+		return literals
+	}
+	return []Literal{FromInterfaces(segment.PathInterfaces()...).(Literal)}
+}
+
+// Flatten returns the canonical form of a segment. A Literal is returned as
+// it is, a Composition is flattened into a Composition that consists only of
+// literals. A Composition of a single literal is reduced to that literal. Two
+// segments have the same canonical form if and only if they consist of the
+// same sequence of literals, regardless of how they are nested.
+func Flatten(segment Segment) Segment {
+	literals := Literals(segment)
+	if len(literals) == 1 {
+		return literals[0]
+	}
+	segments := make([]Segment, len(literals))
+	for i, literal := range literals {
+		segments[i] = literal
+	}
+	return FromSegments(segments...)
+}
@@ -0,0 +1,164 @@
+package segment
+
+import (
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// Graph is the AS-level graph that is implicitly described by a SegmentSet.
+// The nodes of the graph are ISD-AS addresses. There are two kinds of edges:
+// segments, which connect their source and destination ISD-AS, and links,
+// which connect two consecutive ASes within a segment. Segments can be marked
+// as accepted or rejected, e.g., to visualize the result of a negotiation.
+type Graph struct {
+	segset   SegmentSet
+	nodes    []addr.IA
+	outgoing map[addr.IA][]Segment
+	links    []Link
+	adjacent map[addr.IA][]addr.IA
+	accepted map[string]bool
+}
+
+// Link is a directed inter-AS link between an egress and an ingress interface.
+type Link struct {
+	// From is the egress interface of the link.
+	From snet.PathInterface
+	// To is the ingress interface of the link.
+	To snet.PathInterface
+}
+
+// NewGraph creates the Graph that is described by the given SegmentSet. The
+// source and destination ISD-AS addresses of the SegmentSet are always nodes
+// of the graph.
+func NewGraph(ss SegmentSet) *Graph {
+	g := &Graph{
+		segset:   ss,
+		outgoing: make(map[addr.IA][]Segment),
+		adjacent: make(map[addr.IA][]addr.IA),
+	}
+	seenNodes := make(map[addr.IA]bool)
+	addNode := func(ia addr.IA) {
+		if !seenNodes[ia] {
+			seenNodes[ia] = true
+			g.nodes = append(g.nodes, ia)
+		}
+	}
+	addNode(ss.SrcIA)
+	addNode(ss.DstIA)
+	seenLinks := make(map[Link]bool)
+	for _, segment := range ss.Segments {
+		srcIA, dstIA := segment.SrcIA(), segment.DstIA()
+		if srcIA != dstIA { // cyclic segments are no edges
+			g.outgoing[srcIA] = append(g.outgoing[srcIA], segment)
+		}
+		interfaces := segment.PathInterfaces()
+		for i, iface := range interfaces {
+			addNode(iface.IA)
+			if i%2 == 1 {
+				link := Link{From: interfaces[i-1], To: iface}
+				if !seenLinks[link] {
+					seenLinks[link] = true
+					g.links = append(g.links, link)
+					g.adjacent[link.From.IA] = append(g.adjacent[link.From.IA], link.To.IA)
+				}
+			}
+		}
+	}
+	return g
+}
+
+// Nodes returns the ISD-AS addresses in the graph, in order of appearance.
+func (g *Graph) Nodes() []addr.IA {
+	return append([]addr.IA(nil), g.nodes...)
+}
+
+// Segments returns the segments from which the graph was created.
+func (g *Graph) Segments() []Segment {
+	return append([]Segment(nil), g.segset.Segments...)
+}
+
+// Links returns the inter-AS links of the segments in the graph.
+func (g *Graph) Links() []Link {
+	return append([]Link(nil), g.links...)
+}
+
+// Outgoing returns the segments that start at the given ISD-AS and end at a
+// different ISD-AS.
+func (g *Graph) Outgoing(ia addr.IA) []Segment {
+	return g.outgoing[ia]
+}
+
+// Neighbors returns the ISD-AS addresses that are connected to the given
+// ISD-AS by an inter-AS link in the direction of the segments. Each neighbor
+// is returned only once.
+func (g *Graph) Neighbors(ia addr.IA) []addr.IA {
+	seen := make(map[addr.IA]bool)
+	neighbors := make([]addr.IA, 0)
+	for _, neighbor := range g.adjacent[ia] {
+		if !seen[neighbor] {
+			seen[neighbor] = true
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors
+}
+
+// ReachableFrom returns the ISD-AS addresses that can be reached from the
+// given ISD-AS by joining segments at their endpoints, excluding the given
+// ISD-AS itself unless it lies on a cycle. The number of joined segments is
+// not limited.
+func (g *Graph) ReachableFrom(ia addr.IA) []addr.IA {
+	seen := make(map[addr.IA]bool)
+	reachable := make([]addr.IA, 0)
+	queue := []addr.IA{ia}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, segment := range g.outgoing[current] {
+			next := segment.DstIA()
+			if !seen[next] {
+				seen[next] = true
+				reachable = append(reachable, next)
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reachable
+}
+
+// Reachable reports whether the ISD-AS to can be reached from the ISD-AS
+// from by joining segments at their endpoints.
+func (g *Graph) Reachable(from, to addr.IA) bool {
+	if from == to {
+		return true
+	}
+	for _, ia := range g.ReachableFrom(from) {
+		if ia == to {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkAccepted marks the segments of the graph that are contained in the
+// given SegmentSet as accepted and all other segments as rejected. Segments
+// are compared by their sequence of path interfaces. An accepted end-to-end
+// path also marks the segments of which it consists as accepted.
+func (g *Graph) MarkAccepted(accepted SegmentSet) {
+	g.accepted = make(map[string]bool)
+	for _, segment := range accepted.Segments {
+		g.accepted[InterfaceFingerprint(segment)] = true
+		for _, literal := range Literals(segment) {
+			g.accepted[InterfaceFingerprint(literal)] = true
+		}
+	}
+}
+
+// Accepted reports whether a segment has been marked as accepted. The second
+// return value is false if no segments have been marked yet.
+func (g *Graph) Accepted(segment Segment) (accepted, marked bool) {
+	if g.accepted == nil {
+		return false, false
+	}
+	return g.accepted[InterfaceFingerprint(segment)], true
+}
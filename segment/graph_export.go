@@ -0,0 +1,155 @@
+package segment
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes the graph in the Graphviz DOT format to the given writer.
+// Segments are drawn as solid edges, links as dotted gray edges. If segments
+// have been marked, accepted segments are drawn in green and rejected
+// segments are drawn as dashed red edges.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph segments {\n")
+	sb.WriteString("\tnode [shape=box];\n")
+	for _, ia := range g.nodes {
+		attrs := ""
+		if ia == g.segset.SrcIA || ia == g.segset.DstIA {
+			attrs = " [style=bold]"
+		}
+		fmt.Fprintf(&sb, "\t%s%s;\n", strconv.Quote(ia.String()), attrs)
+	}
+	for _, segment := range g.segset.Segments {
+		attrs := fmt.Sprintf("label=%s, tooltip=%s",
+			strconv.Quote(segmentLabel(segment)), strconv.Quote(segment.String()))
+		switch g.status(segment) {
+		case "accepted":
+			attrs += ", color=darkgreen, penwidth=2"
+		case "rejected":
+			attrs += ", color=red, style=dashed"
+		}
+		fmt.Fprintf(&sb, "\t%s -> %s [%s];\n", strconv.Quote(segment.SrcIA().String()),
+			strconv.Quote(segment.DstIA().String()), attrs)
+	}
+	for _, link := range g.links {
+		fmt.Fprintf(&sb, "\t%s -> %s [label=\"%d>%d\", style=dotted, color=gray];\n",
+			strconv.Quote(link.From.IA.String()), strconv.Quote(link.To.IA.String()),
+			link.From.ID, link.To.ID)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteGraphML writes the graph in the GraphML format to the given writer.
+// Every edge has a "kind" (segment or link) and every segment edge has a
+// "status" (accepted, rejected or unmarked) and a "segment" attribute.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "kind", For: "edge", Name: "kind", Type: "string"},
+			{ID: "status", For: "edge", Name: "status", Type: "string"},
+			{ID: "segment", For: "edge", Name: "segment", Type: "string"},
+			{ID: "type", For: "edge", Name: "type", Type: "string"},
+		},
+		Graph: graphMLGraph{ID: "segments", EdgeDefault: "directed"},
+	}
+	for _, ia := range g.nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: ia.String()})
+	}
+	for i, segment := range g.segset.Segments {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     fmt.Sprintf("s%d", i),
+			Source: segment.SrcIA().String(),
+			Target: segment.DstIA().String(),
+			Data: []graphMLData{
+				{Key: "kind", Value: "segment"},
+				{Key: "status", Value: g.status(segment)},
+				{Key: "segment", Value: segment.String()},
+				{Key: "type", Value: segmentLabel(segment)},
+			},
+		})
+	}
+	for i, link := range g.links {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     fmt.Sprintf("l%d", i),
+			Source: link.From.IA.String(),
+			Target: link.To.IA.String(),
+			Data:   []graphMLData{{Key: "kind", Value: "link"}},
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (g *Graph) status(segment Segment) string {
+	accepted, marked := g.Accepted(segment)
+	switch {
+	case !marked:
+		return "unmarked"
+	case accepted:
+		return "accepted"
+	}
+	return "rejected"
+}
+
+// segmentLabel returns the type of a literal or the types of the literals of
+// a composition.
+func segmentLabel(segment Segment) string {
+	literals := Literals(segment)
+	types := make([]string, len(literals))
+	for i, literal := range literals {
+		types[i] = literal.Type.String()
+	}
+	return strings.Join(types, "+")
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID string `xml:"id,attr"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
@@ -0,0 +1,46 @@
+package segment
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+func TestGraph(t *testing.T) {
+	ab := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")
+	bd := FromString("1-ffaa:0:2 2>1 1-ffaa:0:3 2>1 1-ffaa:0:4")
+	ce := FromString("1-ffaa:0:3 3>1 1-ffaa:0:5")
+	srcIA, _ := addr.IAFromString("1-ffaa:0:1")
+	dstIA, _ := addr.IAFromString("1-ffaa:0:4")
+	ia3, _ := addr.IAFromString("1-ffaa:0:3")
+	ia5, _ := addr.IAFromString("1-ffaa:0:5")
+	g := NewGraph(SegmentSet{Segments: []Segment{ab, bd, ce}, SrcIA: srcIA, DstIA: dstIA})
+
+	if len(g.Nodes()) != 5 || len(g.Links()) != 4 {
+		t.Error("want 5 nodes and 4 links, have:", len(g.Nodes()), len(g.Links()))
+	}
+	if neighbors := g.Neighbors(ia3); len(neighbors) != 2 {
+		t.Error("want 2 neighbors, have:", neighbors)
+	}
+	if !g.Reachable(srcIA, dstIA) || g.Reachable(srcIA, ia5) || g.Reachable(dstIA, srcIA) {
+		t.Error("wrong reachability")
+	}
+
+	g.MarkAccepted(SegmentSet{Segments: []Segment{FromSegments(ab, bd)}})
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(dot.String(), "darkgreen") != 2 || strings.Count(dot.String(), "red") != 1 {
+		t.Error("wrong highlighting:\n", dot.String())
+	}
+	var graphml bytes.Buffer
+	if err := g.WriteGraphML(&graphml); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(graphml.String(), "<edge ") != 7 {
+		t.Error("want 7 edges:\n", graphml.String())
+	}
+}
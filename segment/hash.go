@@ -1,14 +1,30 @@
 package segment
 
 import (
-	"github.com/mblarer/conpass/path"
-	"github.com/scionproto/scion/go/lib/snet"
+	"fmt"
+	"strings"
 )
 
-// Hash creates a string that uniquely identifies a segment with high
-// probability solely based on the sequence of its path interfaces.
+// Hash creates a string that uniquely identifies a segment solely based on the
+// sequence of its path interfaces.
+//
+// Deprecated: Hash is the same identity as InterfaceFingerprint, which should
+// be used instead.
 func Hash(segment Segment) string {
-	path := path.InterfacePath{segment.PathInterfaces()}
-	fingerprint := snet.Fingerprint(path)
-	return string(fingerprint)
+	return InterfaceFingerprint(segment)
+}
+
+// InterfaceFingerprint creates a string that uniquely identifies a segment
+// solely based on the sequence of its path interfaces. The fingerprints of
+// Literals and Compositions are already independent of their structure, i.e.,
+// a Literal and a (nested) Composition covering the same interfaces have the
+// same Segment.Fingerprint, which is equal to their InterfaceFingerprint.
+// InterfaceFingerprint extends this identity to other implementations of
+// Segment. The result is compatible with path.Fingerprint.
+func InterfaceFingerprint(segment Segment) string {
+	var sb strings.Builder
+	for _, iface := range segment.PathInterfaces() {
+		sb.WriteString(fmt.Sprintf(" %s#%d ", iface.IA, iface.ID))
+	}
+	return sb.String()
 }
@@ -25,9 +25,24 @@ func FromInterfaces(interfaces ...snet.PathInterface) Segment {
 
 // FromString creates a new Segment from its string representation. This
 // function is mainly intended for testing purposes and will panic if the
-// provided string cannot be parsed into a valid segment.
+// provided string cannot be parsed into a valid segment, see ParseSegment.
 func FromString(segstr string) Segment {
+	segment, err := ParseSegment(segstr)
+	if err != nil {
+		panic(err)
+	}
+	return segment
+}
+
+// ParseSegment creates a new Segment from its string representation, e.g.,
+// "1-ffaa:0:1 1>2 1-ffaa:0:2 3>1 1-ffaa:0:3", which is the format in which
+// segment literals are printed. An error is returned if the string cannot be
+// parsed into a valid segment.
+func ParseSegment(segstr string) (Segment, error) {
 	fields := strings.Fields(segstr)
+	if len(fields) < 3 || len(fields)%2 == 0 {
+		return nil, fmt.Errorf("invalid segment %q: expected ISD-AS and interface pairs", segstr)
+	}
 	length := len(fields) - 1
 	iastrs := make([]string, length)
 	idstrs := make([]string, length)
@@ -35,33 +50,39 @@ func FromString(segstr string) Segment {
 		if i%2 == 0 {
 			iastrs[i] = fields[i]
 			ids := strings.Split(fields[i+1], ">")
+			if len(ids) != 2 {
+				return nil, fmt.Errorf("invalid segment %q: invalid link %q", segstr, fields[i+1])
+			}
 			idstrs[i], idstrs[i+1] = ids[0], ids[1]
 		} else {
 			iastrs[i] = fields[i+1]
 		}
 	}
 	interfaces := make([]snet.PathInterface, length)
-	var sb strings.Builder
 	for i := 0; i < length; i++ {
 		ia, err := addr.IAFromString(iastrs[i])
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("invalid segment %q: %s", segstr, err)
 		}
-		id, err := strconv.Atoi(idstrs[i])
+		id, err := strconv.ParseUint(idstrs[i], 10, 64)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("invalid segment %q: invalid interface ID %q", segstr, idstrs[i])
 		}
 		interfaces[i] = snet.PathInterface{ID: common.IFIDType(id), IA: ia}
-		sb.WriteString(fmt.Sprintf(" %s#%d ", ia, common.IFIDType(id)))
 	}
-	return Literal{Interfaces: interfaces, fingerprint: sb.String()}
+	return FromInterfaces(interfaces...), nil
 }
 
 // Literal implements the Segment interface.
 type Literal struct {
 	// Interfaces is the sequence of ingress-egress interfaces of which the
 	// segment literal consists.
-	Interfaces  []snet.PathInterface
+	Interfaces []snet.PathInterface
+	// Type is the type of the segment literal, e.g., up-segment, if known.
+	Type Type
+	// Dir is the direction in which the segment literal is traversed, if
+	// known.
+	Dir         Direction
 	fingerprint string
 }
 
@@ -81,6 +102,26 @@ func (l Literal) Fingerprint() string {
 	return l.fingerprint
 }
 
+// transitIndex returns the index of the ingress interface of the given ISD-AS
+// if the literal crosses it, i.e., if it is neither the first nor the last
+// ISD-AS of the literal. Otherwise, -1 is returned.
+func (l Literal) transitIndex(ia addr.IA) int {
+	for i := 1; i < len(l.Interfaces)-1; i += 2 {
+		if l.Interfaces[i].IA == ia {
+			return i
+		}
+	}
+	return -1
+}
+
+// slice returns a new literal that consists of the interfaces from index
+// first (inclusive) to index last (exclusive) of the literal.
+func (l Literal) slice(first, last int) Literal {
+	literal := FromInterfaces(l.Interfaces[first:last]...).(Literal)
+	literal.Type, literal.Dir = l.Type, l.Dir
+	return literal
+}
+
 func (l Literal) String() string {
 	str := ""
 	for i, iface := range l.Interfaces {
@@ -0,0 +1,51 @@
+package segment
+
+import (
+	"time"
+
+	"github.com/scionproto/scion/go/lib/snet"
+)
+
+// Metric assigns a cost to a segment, where a lower cost is better. Metrics
+// that are used for a best-first search, e.g., by KShortestPaths, must be
+// non-negative and additive, i.e., the cost of a composition must be equal to
+// the sum of the costs of its subsegments.
+type Metric interface {
+	// Cost returns the cost of the given segment.
+	Cost(Segment) float64
+}
+
+// MetricFunc is an adapter to allow the use of ordinary functions as a Metric.
+type MetricFunc func(Segment) float64
+
+// Cost returns f(segment).
+func (f MetricFunc) Cost(segment Segment) float64 {
+	return f(segment)
+}
+
+// HopCount is a Metric that counts the number of inter-AS links of a segment.
+var HopCount Metric = MetricFunc(func(segment Segment) float64 {
+	return float64(len(segment.PathInterfaces()) / 2)
+})
+
+// SegmentCount is a Metric that counts the number of literals of a segment.
+var SegmentCount Metric = MetricFunc(func(segment Segment) float64 {
+	return float64(len(Literals(segment)))
+})
+
+// Latency returns a Metric that sums up the latencies between consecutive
+// interfaces of the literals of a segment, in milliseconds. The latency
+// function is provided by the caller, e.g., based on the metadata of the SCION
+// paths from which the segments were split. The latency within the ASes where
+// two literals are joined is not taken into account.
+func Latency(latency func(from, to snet.PathInterface) time.Duration) Metric {
+	return MetricFunc(func(segment Segment) float64 {
+		var total time.Duration
+		for _, literal := range Literals(segment) {
+			for i := 1; i < len(literal.Interfaces); i++ {
+				total += latency(literal.Interfaces[i-1], literal.Interfaces[i])
+			}
+		}
+		return float64(total) / float64(time.Millisecond)
+	})
+}
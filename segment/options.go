@@ -0,0 +1,113 @@
+package segment
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// The options of a segment entry are a sequence of type-length-value encoded
+// options, each consisting of a one-byte type, a one-byte length and the
+// value. Options of unknown types are skipped.
+const (
+	// The preference score of a segment is encoded as an IEEE 754 double.
+	optPreference    uint8 = 1
+	optPreferenceLen uint8 = 8
+	// The validity interval of the consent to a segment is encoded as two
+	// Unix times in seconds, where 0 means that there is no bound.
+	optValidity    uint8 = 2
+	optValidityLen uint8 = 16
+	// The bandwidth cap is encoded in bits per second.
+	optBandwidth    uint8 = 3
+	optBandwidthLen uint8 = 8
+	// The traffic volume cap is encoded in bytes.
+	optVolume    uint8 = 4
+	optVolumeLen uint8 = 8
+	// The priority class is encoded in a single byte.
+	optPriority    uint8 = 5
+	optPriorityLen uint8 = 1
+)
+
+// encodeOptions returns the options of the segment entry of a segment in the
+// given message.
+func encodeOptions(segment Segment, message Message) []byte {
+	options := make([]byte, 0)
+	if preference, ok := message.Preferences[InterfaceFingerprint(segment)]; ok {
+		value := make([]byte, optPreferenceLen)
+		binary.BigEndian.PutUint64(value, math.Float64bits(preference))
+		options = append(options, optPreference, optPreferenceLen)
+		options = append(options, value...)
+	}
+	conditions := message.Conditions[InterfaceFingerprint(segment)]
+	if !conditions.NotBefore.IsZero() || !conditions.NotAfter.IsZero() {
+		value := make([]byte, optValidityLen)
+		binary.BigEndian.PutUint64(value, uint64(unixTime(conditions.NotBefore)))
+		binary.BigEndian.PutUint64(value[8:], uint64(unixTime(conditions.NotAfter)))
+		options = append(options, optValidity, optValidityLen)
+		options = append(options, value...)
+	}
+	if conditions.Bandwidth != 0 {
+		value := make([]byte, optBandwidthLen)
+		binary.BigEndian.PutUint64(value, conditions.Bandwidth)
+		options = append(options, optBandwidth, optBandwidthLen)
+		options = append(options, value...)
+	}
+	if conditions.Volume != 0 {
+		value := make([]byte, optVolumeLen)
+		binary.BigEndian.PutUint64(value, conditions.Volume)
+		options = append(options, optVolume, optVolumeLen)
+		options = append(options, value...)
+	}
+	if conditions.Priority != 0 {
+		options = append(options, optPriority, optPriorityLen, conditions.Priority)
+	}
+	return options
+}
+
+// decodeOptions decodes the options of the segment entry of a segment into
+// the given message.
+func decodeOptions(options []byte, segment Segment, message *Message) {
+	key := InterfaceFingerprint(segment)
+	conditions := message.Conditions[key]
+	for len(options) >= 2 {
+		opttype, optlen := options[0], int(options[1])
+		if len(options) < 2+optlen {
+			break
+		}
+		value := options[2 : 2+optlen]
+		switch {
+		case opttype == optPreference && optlen == int(optPreferenceLen):
+			preference := math.Float64frombits(binary.BigEndian.Uint64(value))
+			message.Preferences[key] = preference
+		case opttype == optValidity && optlen == int(optValidityLen):
+			conditions.NotBefore = fromUnixTime(int64(binary.BigEndian.Uint64(value)))
+			conditions.NotAfter = fromUnixTime(int64(binary.BigEndian.Uint64(value[8:])))
+		case opttype == optBandwidth && optlen == int(optBandwidthLen):
+			conditions.Bandwidth = binary.BigEndian.Uint64(value)
+		case opttype == optVolume && optlen == int(optVolumeLen):
+			conditions.Volume = binary.BigEndian.Uint64(value)
+		case opttype == optPriority && optlen == int(optPriorityLen):
+			conditions.Priority = value[0]
+		}
+		options = options[2+optlen:]
+	}
+	if !conditions.IsZero() {
+		message.Conditions[key] = conditions
+	}
+}
+
+// unixTime returns the Unix time of t in seconds, or 0 if t is the zero time.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// fromUnixTime is the inverse of unixTime.
+func fromUnixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
@@ -0,0 +1,71 @@
+package segment
+
+import (
+	"math"
+	"sort"
+)
+
+// Preference returns the preference score of a segment according to the
+// given scores, which are keyed by InterfaceFingerprint. If there is no score
+// for a composition, its score is the minimum score of its subsegments, i.e.,
+// a path is only as good as its worst segment. Segments without any score
+// have the score 0.
+func Preference(preferences map[string]float64, segment Segment) float64 {
+	score, _ := preference(preferences, segment)
+	return score
+}
+
+func preference(preferences map[string]float64, segment Segment) (float64, bool) {
+	if score, ok := preferences[InterfaceFingerprint(segment)]; ok {
+		return score, true
+	}
+	composition, ok := segment.(Composition)
+	if !ok {
+		return 0, false
+	}
+	min, found := math.Inf(1), false
+	for _, subseg := range composition.Segments {
+		if score, ok := preference(preferences, subseg); ok {
+			min, found = math.Min(min, score), true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	return min, true
+}
+
+// Aggregation combines the preference scores of the Initiator and the
+// Responder for a segment into a joint score, where a higher score is better.
+type Aggregation func(initiator, responder float64) float64
+
+var (
+	// AggregateSum ranks segments by the sum of both scores, i.e., by their
+	// utilitarian welfare.
+	AggregateSum Aggregation = func(initiator, responder float64) float64 {
+		return initiator + responder
+	}
+	// AggregateMin ranks segments by the lower of both scores, i.e., in
+	// favor of the agent that is worse off.
+	AggregateMin Aggregation = math.Min
+	// AggregateNash ranks segments by the product of both scores, i.e., by
+	// the Nash bargaining solution. The scores must be non-negative.
+	AggregateNash Aggregation = func(initiator, responder float64) float64 {
+		return initiator * responder
+	}
+)
+
+// JointRanking orders segments by decreasing joint score, which is the
+// aggregation of the preference scores of the Initiator and the Responder.
+// Segments with equal joint scores keep their relative order.
+func JointRanking(segments []Segment, initiator, responder map[string]float64, aggregate Aggregation) []Segment {
+	scores := make(map[string]float64, len(segments))
+	for _, segment := range segments {
+		scores[segment.Fingerprint()] = aggregate(Preference(initiator, segment), Preference(responder, segment))
+	}
+	ranked := append([]Segment(nil), segments...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i].Fingerprint()] > scores[ranked[j].Fingerprint()]
+	})
+	return ranked
+}
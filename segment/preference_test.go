@@ -0,0 +1,60 @@
+package segment
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPreference(t *testing.T) {
+	ab := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")
+	bc := FromString("1-ffaa:0:2 2>1 1-ffaa:0:3")
+	cd := FromString("1-ffaa:0:3 2>1 1-ffaa:0:4")
+	preferences := map[string]float64{
+		InterfaceFingerprint(ab): 3,
+		InterfaceFingerprint(bc): 2,
+	}
+	tests := []struct {
+		name    string
+		segment Segment
+		want    float64
+	}{
+		{"literal", ab, 3},
+		{"literal without score", cd, 0},
+		{"minimum of subsegments", FromSegments(ab, bc), 2},
+		{"subsegments without score are ignored", FromSegments(FromSegments(ab, bc), cd), 2},
+	}
+	for _, test := range tests {
+		if have := Preference(preferences, test.segment); have != test.want {
+			t.Error(test.name, "want:", test.want, "have:", have)
+		}
+	}
+}
+
+func TestPreferencesAreTransmitted(t *testing.T) {
+	ab := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")
+	bc := FromString("1-ffaa:0:2 2>1 1-ffaa:0:3")
+	abc := FromSegments(ab, bc)
+	message := Message{
+		Segments:    []Segment{abc},
+		Preferences: map[string]float64{InterfaceFingerprint(ab): 0.5, InterfaceFingerprint(abc): 2},
+	}
+	var buffer bytes.Buffer
+	if _, err := WriteMessage(&buffer, message, []Segment{}); err != nil {
+		t.Fatal(err)
+	}
+	received, _, err := ReadMessage(&buffer, []Segment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received.Segments) != 1 || received.Segments[0].Fingerprint() != abc.Fingerprint() {
+		t.Fatal("want:", abc, "have:", received.Segments)
+	}
+	if len(received.Preferences) != 2 {
+		t.Fatal("want 2 preferences, have:", received.Preferences)
+	}
+	for key, want := range message.Preferences {
+		if have := received.Preferences[key]; have != want {
+			t.Error("want:", want, "have:", have)
+		}
+	}
+}
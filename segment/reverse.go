@@ -0,0 +1,47 @@
+package segment
+
+import "github.com/scionproto/scion/go/lib/snet"
+
+// Reverse returns a segment traversed in the opposite direction, i.e., from
+// its destination ISD-AS to its source ISD-AS. The subsegments of a
+// Composition are reversed recursively. Up-segments become down-segments and
+// vice versa. Segments that are neither a Literal nor a Composition are
+// reversed into a single Literal.
+func Reverse(segment Segment) Segment {
+	switch s := segment.(type) {
+	case Literal:
+		return reverseLiteral(s)
+	case Composition:
+		length := len(s.Segments)
+		segments := make([]Segment, length)
+		for i, subseg := range s.Segments {
+			segments[length-1-i] = Reverse(subseg)
+		}
+		return FromSegments(segments...)
+	}
+	return reverseLiteral(FromInterfaces(segment.PathInterfaces()...).(Literal))
+}
+
+func reverseLiteral(l Literal) Literal {
+	length := len(l.Interfaces)
+	interfaces := make([]snet.PathInterface, length)
+	for i, iface := range l.Interfaces {
+		interfaces[length-1-i] = iface
+	}
+	reversed := FromInterfaces(interfaces...).(Literal)
+	switch l.Type {
+	case TypeUp:
+		reversed.Type = TypeDown
+	case TypeDown:
+		reversed.Type = TypeUp
+	default:
+		reversed.Type = l.Type
+	}
+	switch l.Dir {
+	case DirConstruction:
+		reversed.Dir = DirAgainstConstruction
+	case DirAgainstConstruction:
+		reversed.Dir = DirConstruction
+	}
+	return reversed
+}
@@ -0,0 +1,23 @@
+package segment
+
+import "testing"
+
+func TestReverse(t *testing.T) {
+	up := FromString("1-ffaa:0:111 1>1 1-ffaa:0:110").(Literal)
+	up.Type, up.Dir = TypeUp, DirAgainstConstruction
+	core := FromString("1-ffaa:0:110 2>1 1-ffaa:0:100")
+	seg := FromSegments(FromSegments(up, core))
+
+	reversed := Reverse(seg)
+	want := FromString("1-ffaa:0:100 1>2 1-ffaa:0:110 1>1 1-ffaa:0:111")
+	if InterfaceFingerprint(reversed) != InterfaceFingerprint(want) {
+		t.Error("want:", want, "have:", reversed)
+	}
+	literals := Literals(reversed)
+	if len(literals) != 2 || literals[1].Type != TypeDown || literals[1].Dir != DirConstruction {
+		t.Error("reversed up-segment is no down-segment in construction direction:", literals)
+	}
+	if Reverse(reversed).Fingerprint() != seg.Fingerprint() {
+		t.Error("want:", seg, "have:", Reverse(reversed))
+	}
+}
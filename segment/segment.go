@@ -17,7 +17,9 @@ type Segment interface {
 	SrcIA() addr.IA
 	// DstIA returns the segment's destination ISD-AS address.
 	DstIA() addr.IA
-	// Fingerprint returns a string that uniquely identifies the segment.
+	// Fingerprint returns a string that uniquely identifies the segment. The
+	// fingerprints of Literals and Compositions only depend on the sequence
+	// of path interfaces, see InterfaceFingerprint.
 	Fingerprint() string
 	// Segment implements the fmt.Stringer interface.
 	fmt.Stringer
@@ -15,15 +15,34 @@ type SegmentSet struct {
 	SrcIA addr.IA
 	// DstIA is the destination ISD-AS address of the SegmentSet.
 	DstIA addr.IA
+	// Preferences maps the InterfaceFingerprint of a segment to the
+	// preference score that the agent assigns to it, where a higher score is
+	// better, see Preference. Filters that assign scores must not modify the
+	// map in place, since it is shared by copies of the SegmentSet.
+	Preferences map[string]float64
+	// Conditions maps the InterfaceFingerprint of a segment to the
+	// conditions under which the agents consent to it, see ConditionsOf. In
+	// the result of a negotiation, they are the combined conditions of both
+	// agents. Filters must not modify the map in place.
+	Conditions map[string]Conditions
+	// Trace records the decisions of the filters that have been applied to
+	// the SegmentSet. It is nil unless tracing is enabled, see NewTrace.
+	Trace *Trace
 }
 
+// Identity maps a segment to a string that determines whether two segments
+// are considered equal. Both Segment.Fingerprint and InterfaceFingerprint can
+// be used as an Identity. They only differ for implementations of Segment
+// other than Literal and Composition.
+type Identity func(Segment) string
+
 // MatchingPaths takes a set of SCION paths and returns the paths that are
 // constructed from segments in the SegmentSet.
 func (ss SegmentSet) MatchingPaths(paths []snet.Path) []snet.Path {
 	matching := make([]snet.Path, 0)
 	accepted := make(map[string]bool)
 	for _, spath := range ss.EnumeratePaths() {
-		accepted[spath.Fingerprint()] = true
+		accepted[InterfaceFingerprint(spath)] = true
 	}
 	for _, spath := range paths {
 		if accepted[path.Fingerprint(spath)] {
@@ -37,3 +56,102 @@ func (ss SegmentSet) MatchingPaths(paths []snet.Path) []snet.Path {
 func (ss SegmentSet) EnumeratePaths() []Segment {
 	return SrcDstPaths(ss.Segments, ss.SrcIA, ss.DstIA)
 }
+
+// EnumeratePathsWithOptions enumerates all end-to-end paths from the given
+// SegmentSet according to the given EnumerationOptions.
+func (ss SegmentSet) EnumeratePathsWithOptions(opts EnumerationOptions) []Segment {
+	return SrcDstPathsWithOptions(ss.Segments, ss.SrcIA, ss.DstIA, opts)
+}
+
+// WithSegments returns a copy of the SegmentSet that contains the given
+// segments instead of the original ones.
+func (ss SegmentSet) WithSegments(segments []Segment) SegmentSet {
+	ss.Segments = segments
+	return ss
+}
+
+// Reverse returns the SegmentSet in the opposite direction, i.e., with
+// reversed segments and swapped source and destination ISD-AS addresses.
+func (ss SegmentSet) Reverse() SegmentSet {
+	reversed := make([]Segment, len(ss.Segments))
+	for i, segment := range ss.Segments {
+		reversed[i] = Reverse(segment)
+	}
+	ss.Segments = reversed
+	ss.SrcIA, ss.DstIA = ss.DstIA, ss.SrcIA
+	return ss
+}
+
+// Contains reports whether the SegmentSet contains a segment that is equal to
+// the given segment according to the given identity.
+func (ss SegmentSet) Contains(segment Segment, id Identity) bool {
+	key := id(segment)
+	for _, seg := range ss.Segments {
+		if id(seg) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Dedup returns a SegmentSet in which segments that are equal according to the
+// given identity only occur once. The first occurrence is kept.
+func (ss SegmentSet) Dedup(id Identity) SegmentSet {
+	return ss.Union(SegmentSet{}, id)
+}
+
+// Union returns a SegmentSet with the segments that are contained in either
+// SegmentSet, according to the given identity. The segments of ss come first,
+// followed by the segments of other that are not contained in ss. The source
+// and destination ISD-AS addresses are taken from ss.
+func (ss SegmentSet) Union(other SegmentSet, id Identity) SegmentSet {
+	seen := make(map[string]bool)
+	union := make([]Segment, 0, len(ss.Segments)+len(other.Segments))
+	for _, segments := range [][]Segment{ss.Segments, other.Segments} {
+		for _, segment := range segments {
+			key := id(segment)
+			if !seen[key] {
+				seen[key] = true
+				union = append(union, segment)
+			}
+		}
+	}
+	return ss.WithSegments(union)
+}
+
+// Intersect returns a SegmentSet with the segments of ss that are also
+// contained in other, according to the given identity. Each segment occurs at
+// most once in the result.
+func (ss SegmentSet) Intersect(other SegmentSet, id Identity) SegmentSet {
+	keys := identities(other.Segments, id)
+	return ss.filterByKeys(keys, id, true)
+}
+
+// Difference returns a SegmentSet with the segments of ss that are not
+// contained in other, according to the given identity. Each segment occurs at
+// most once in the result.
+func (ss SegmentSet) Difference(other SegmentSet, id Identity) SegmentSet {
+	keys := identities(other.Segments, id)
+	return ss.filterByKeys(keys, id, false)
+}
+
+func (ss SegmentSet) filterByKeys(keys map[string]bool, id Identity, keep bool) SegmentSet {
+	seen := make(map[string]bool)
+	filtered := make([]Segment, 0)
+	for _, segment := range ss.Segments {
+		key := id(segment)
+		if keys[key] == keep && !seen[key] {
+			seen[key] = true
+			filtered = append(filtered, segment)
+		}
+	}
+	return ss.WithSegments(filtered)
+}
+
+func identities(segments []Segment, id Identity) map[string]bool {
+	keys := make(map[string]bool, len(segments))
+	for _, segment := range segments {
+		keys[id(segment)] = true
+	}
+	return keys
+}
@@ -0,0 +1,95 @@
+package segment
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInterfaceFingerprintIgnoresStructure(t *testing.T) {
+	ab := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")
+	bc := FromString("1-ffaa:0:2 2>1 1-ffaa:0:3")
+	cd := FromString("1-ffaa:0:3 2>1 1-ffaa:0:4")
+	literal := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2 2>1 1-ffaa:0:3 2>1 1-ffaa:0:4")
+	flat := FromSegments(ab, bc, cd)
+	nested := FromSegments(FromSegments(ab, bc), cd)
+
+	for _, seg := range []Segment{flat, nested, FromSegments(literal)} {
+		if InterfaceFingerprint(seg) != InterfaceFingerprint(literal) {
+			t.Error("want:", InterfaceFingerprint(literal), "have:", InterfaceFingerprint(seg))
+		}
+		if seg.Fingerprint() != literal.Fingerprint() {
+			t.Error("want:", literal.Fingerprint(), "have:", seg.Fingerprint())
+		}
+	}
+	if Flatten(nested).Fingerprint() != flat.Fingerprint() {
+		t.Error("want:", flat.Fingerprint(), "have:", Flatten(nested).Fingerprint())
+	}
+	if Flatten(FromSegments(literal)).Fingerprint() != literal.Fingerprint() {
+		t.Error("composition of a single literal is not flattened to the literal")
+	}
+}
+
+func TestEncodingKeepsFingerprints(t *testing.T) {
+	ab := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")
+	bc := FromString("1-ffaa:0:2 2>1 1-ffaa:0:3")
+	literal := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2 2>1 1-ffaa:0:3")
+	message := Message{Segments: []Segment{literal, FromSegments(ab, bc)}}
+	var buffer bytes.Buffer
+	sentsegs, err := WriteMessage(&buffer, message, []Segment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, newsegs, err := ReadMessage(&buffer, []Segment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received.Segments) != 2 {
+		t.Fatal("want 2 segments, have:", received.Segments)
+	}
+	for _, seg := range received.Segments {
+		if seg.Fingerprint() != literal.Fingerprint() {
+			t.Error("want:", literal.Fingerprint(), "have:", seg.Fingerprint())
+		}
+	}
+
+	// Sending a received segment back refers to it by its index.
+	message = Message{Segments: []Segment{received.Segments[0]}}
+	if _, err := WriteMessage(&buffer, message, newsegs); err != nil {
+		t.Fatal(err)
+	}
+	received, _, err = ReadMessage(&buffer, sentsegs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received.Segments) != 1 || received.Segments[0].Fingerprint() != literal.Fingerprint() {
+		t.Error("want:", literal, "have:", received.Segments)
+	}
+}
+
+func TestSegmentSetOperations(t *testing.T) {
+	ab := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2")
+	bc := FromString("1-ffaa:0:2 2>1 1-ffaa:0:3")
+	literal := FromString("1-ffaa:0:1 1>1 1-ffaa:0:2 2>1 1-ffaa:0:3")
+	composition := FromSegments(ab, bc)
+	ss1 := SegmentSet{Segments: []Segment{ab, literal}}
+	ss2 := SegmentSet{Segments: []Segment{bc, composition}}
+
+	tests := []struct {
+		name string
+		have SegmentSet
+		want int
+	}{
+		{"union by fingerprint", ss1.Union(ss2, Segment.Fingerprint), 3},
+		{"union by interfaces", ss1.Union(ss2, InterfaceFingerprint), 3},
+		{"intersection by fingerprint", ss1.Intersect(ss2, Segment.Fingerprint), 1},
+		{"intersection by interfaces", ss1.Intersect(ss2, InterfaceFingerprint), 1},
+		{"difference by fingerprint", ss1.Difference(ss2, Segment.Fingerprint), 1},
+		{"difference by interfaces", ss1.Difference(ss2, InterfaceFingerprint), 1},
+		{"dedup by interfaces", SegmentSet{Segments: append(ss1.Segments, ss2.Segments...)}.Dedup(InterfaceFingerprint), 3},
+	}
+	for _, test := range tests {
+		if len(test.have.Segments) != test.want {
+			t.Error(test.name, "want:", test.want, "have:", len(test.have.Segments))
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package segment
+
+import (
+	"container/heap"
+
+	"github.com/scionproto/scion/go/lib/addr"
+)
+
+// KShortestPaths returns the k end-to-end paths of a SegmentSet with the
+// lowest cost according to the given Metric, ordered by increasing cost. The
+// paths are constructed according to the given EnumerationOptions. Instead
+// of enumerating all paths, a best-first search over the segments is used,
+// which requires the Metric to be non-negative and additive. Since at most k
+// of the cheapest partial paths to an ISD-AS can be part of the k cheapest
+// paths, every ISD-AS is expanded at most k times.
+func KShortestPaths(ss SegmentSet, k int, metric Metric, opts EnumerationOptions) []Segment {
+	paths := make([]Segment, 0, k)
+	if k <= 0 || ss.SrcIA == ss.DstIA {
+		return paths
+	}
+	candidates := opts.candidates(ss.Segments, ss.SrcIA, ss.DstIA)
+	graph := NewGraph(ss.WithSegments(candidates))
+	costs := make(map[string]float64, len(candidates))
+	for _, segment := range candidates {
+		costs[segment.Fingerprint()] = metric.Cost(segment)
+	}
+	maxlen := opts.maxSegments()
+
+	expanded := make(map[addr.IA]int)
+	queue := &partialPathQueue{}
+	heap.Push(queue, &partialPath{ia: ss.SrcIA})
+	for queue.Len() > 0 && len(paths) < k {
+		current := heap.Pop(queue).(*partialPath)
+		if expanded[current.ia] >= k {
+			continue
+		}
+		expanded[current.ia]++
+		if current.ia == ss.DstIA {
+			paths = append(paths, current.segment())
+			continue
+		}
+		if len(current.seglist) >= maxlen {
+			continue
+		}
+		for _, next := range graph.Outgoing(current.ia) {
+			if current.visits(ss.SrcIA, next.DstIA()) {
+				continue
+			}
+			extended := &partialPath{
+				ia:      next.DstIA(),
+				seglist: append(append([]Segment(nil), current.seglist...), next),
+				cost:    current.cost + costs[next.Fingerprint()],
+				order:   queue.pushed,
+			}
+			if opts.LoopFree && HasLoop(extended.segment()) {
+				continue
+			}
+			heap.Push(queue, extended)
+		}
+	}
+	return paths
+}
+
+// partialPath is a sequence of segments from the source ISD-AS to an
+// intermediate ISD-AS, with the accumulated cost of the segments.
+type partialPath struct {
+	ia      addr.IA
+	seglist []Segment
+	cost    float64
+	order   int
+}
+
+func (p *partialPath) segment() Segment {
+	if len(p.seglist) == 1 {
+		return p.seglist[0]
+	}
+	return FromSegments(p.seglist...)
+}
+
+// visits reports whether the partial path already starts or ends at the given
+// ISD-AS, which would make the extended path cyclic.
+func (p *partialPath) visits(srcIA, ia addr.IA) bool {
+	if srcIA == ia {
+		return true
+	}
+	for _, segment := range p.seglist {
+		if segment.DstIA() == ia {
+			return true
+		}
+	}
+	return false
+}
+
+// partialPathQueue implements heap.Interface. Partial paths with equal cost
+// are popped in the order in which they were pushed.
+type partialPathQueue struct {
+	paths  []*partialPath
+	pushed int
+}
+
+func (q partialPathQueue) Len() int { return len(q.paths) }
+
+func (q partialPathQueue) Less(i, j int) bool {
+	if q.paths[i].cost != q.paths[j].cost {
+		return q.paths[i].cost < q.paths[j].cost
+	}
+	return q.paths[i].order < q.paths[j].order
+}
+
+func (q partialPathQueue) Swap(i, j int) { q.paths[i], q.paths[j] = q.paths[j], q.paths[i] }
+
+func (q *partialPathQueue) Push(x interface{}) {
+	q.paths = append(q.paths, x.(*partialPath))
+	q.pushed++
+}
+
+func (q *partialPathQueue) Pop() interface{} {
+	last := q.paths[len(q.paths)-1]
+	q.paths = q.paths[:len(q.paths)-1]
+	return last
+}
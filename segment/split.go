@@ -1,6 +1,9 @@
 package segment
 
 import (
+	"errors"
+
+	"github.com/scionproto/scion/go/lib/slayers/path"
 	"github.com/scionproto/scion/go/lib/slayers/path/scion"
 	"github.com/scionproto/scion/go/lib/snet"
 )
@@ -26,7 +29,11 @@ func SplitPaths(paths []snet.Path) ([]Segment, error) {
 	return allsegs, nil
 }
 
-// SplitPath splits the given path into up-/core-/down-/peering segments.
+// SplitPath splits the given path into up-/core-/down-/peering segments. The
+// resulting literals carry the segment type and the direction in which they
+// are traversed, as far as they can be inferred from the path meta header.
+// Both peering segments of a path contain the interfaces of the peering link,
+// i.e., the first one ends and the second one starts with the peering link.
 func SplitPath(path snet.Path) ([]Segment, error) {
 	decoded := new(scion.Decoded)
 	if err := decoded.DecodeFromBytes(path.Path().Raw); err != nil {
@@ -34,22 +41,73 @@ func SplitPath(path snet.Path) ([]Segment, error) {
 	}
 	interfaces := path.Metadata().Interfaces
 	seglen := decoded.PathMeta.SegLen
+	types := segmentTypes(decoded.InfoFields)
 	segments := make([]Segment, 0)
+	first := uint(0)
 	for i := uint(0); i < 3; i++ {
-		if seglen[i] > 0 {
-			segments = append(segments, ithSegment(i, interfaces, seglen))
+		if seglen[i] == 0 {
+			continue
+		}
+		info := decoded.InfoFields[len(segments)]
+		last := first + numInterfaces(seglen[i])
+		if info.Peer {
+			// the peering hop field adds the interface of the peering link
+			last++
+		}
+		if last > uint(len(interfaces)) {
+			return nil, errors.New("path metadata does not match path segments")
+		}
+		begin, end := first, last
+		if info.Peer && len(segments) == 0 && end < uint(len(interfaces)) {
+			end++
+		} else if info.Peer && len(segments) > 0 && begin > 0 {
+			begin--
+		}
+		literal := FromInterfaces(interfaces[begin:end]...).(Literal)
+		literal.Type = types[len(segments)]
+		if literal.Type == TypeUnspecified && literal.SrcIA().I != literal.DstIA().I {
+			// up-segments do not leave their ISD
+			literal.Type = TypeCore
 		}
+		literal.Dir = DirAgainstConstruction
+		if info.ConsDir {
+			literal.Dir = DirConstruction
+		}
+		segments = append(segments, literal)
+		first = last
 	}
 	return segments, nil
 }
 
-func ithSegment(i uint, interfaces []snet.PathInterface, seglen [3]uint8) Segment {
-	firstIf := uint(0)
-	for j := uint(0); j < i; j++ {
-		firstIf += numInterfaces(seglen[j])
+// segmentTypes infers the segment types from the info fields of a path. Up-
+// and core-segments are traversed against construction direction, whereas
+// down-segments are traversed in construction direction. A single segment
+// against construction direction, whether alone or followed by a
+// down-segment, may be an up- or a core-segment and is left unspecified.
+// SplitPath labels it as a core-segment if it crosses ISDs.
+func segmentTypes(infos []*path.InfoField) []Type {
+	types := make([]Type, len(infos))
+	against := 0
+	for _, info := range infos {
+		if !info.ConsDir {
+			against++
+		}
+	}
+	for i, info := range infos {
+		switch {
+		case info.Peer:
+			types[i] = TypePeering
+		case info.ConsDir:
+			types[i] = TypeDown
+		case i == 0 && against == 2:
+			types[i] = TypeUp
+		case i == 1 && against == 2:
+			types[i] = TypeCore
+		default:
+			types[i] = TypeUnspecified
+		}
 	}
-	lastIfExcl := firstIf + numInterfaces(seglen[i])
-	return FromInterfaces(interfaces[firstIf:lastIfExcl]...)
+	return types
 }
 
 func numInterfaces(seglen uint8) uint {
@@ -0,0 +1,92 @@
+package segment
+
+import (
+	"testing"
+
+	"github.com/scionproto/scion/go/lib/slayers/path"
+	"github.com/scionproto/scion/go/lib/slayers/path/scion"
+	"github.com/scionproto/scion/go/lib/snet"
+	snetpath "github.com/scionproto/scion/go/lib/snet/path"
+	"github.com/scionproto/scion/go/lib/spath"
+)
+
+// testPath builds a path with the given info fields and segment lengths whose
+// metadata contains the interfaces of the given segment.
+func testPath(t *testing.T, seg Segment, infos []*path.InfoField, seglen [3]uint8) snet.Path {
+	decoded := &scion.Decoded{
+		Base: scion.Base{PathMeta: scion.MetaHdr{SegLen: seglen}, NumINF: len(infos)},
+	}
+	for _, l := range seglen {
+		decoded.NumHops += int(l)
+	}
+	decoded.InfoFields = infos
+	decoded.HopFields = make([]*path.HopField, decoded.NumHops)
+	for i := range decoded.HopFields {
+		decoded.HopFields[i] = &path.HopField{}
+	}
+	raw := make([]byte, decoded.Len())
+	if err := decoded.SerializeTo(raw); err != nil {
+		t.Fatal(err)
+	}
+	return snetpath.Path{
+		SPath: spath.Path{Raw: raw, Type: scion.PathType},
+		Meta:  snet.PathMetadata{Interfaces: seg.PathInterfaces()},
+	}
+}
+
+func TestSplitPathTypes(t *testing.T) {
+	against := &path.InfoField{}
+	construction := &path.InfoField{ConsDir: true}
+	tests := []struct {
+		name   string
+		seg    Segment
+		infos  []*path.InfoField
+		seglen [3]uint8
+		want   []Type
+	}{
+		{
+			"up and core",
+			FromString("1-ffaa:0:111 1>1 1-ffaa:0:110 2>1 2-ffaa:0:200"),
+			[]*path.InfoField{against, against},
+			[3]uint8{2, 2, 0},
+			[]Type{TypeUp, TypeCore},
+		},
+		{
+			"ambiguous first segment",
+			FromString("1-ffaa:0:111 1>1 1-ffaa:0:110 2>1 1-ffaa:0:112"),
+			[]*path.InfoField{against, construction},
+			[3]uint8{2, 2, 0},
+			[]Type{TypeUnspecified, TypeDown},
+		},
+		{
+			"lone ambiguous segment",
+			FromString("1-ffaa:0:111 1>1 1-ffaa:0:110"),
+			[]*path.InfoField{against},
+			[3]uint8{2, 0, 0},
+			[]Type{TypeUnspecified},
+		},
+		{
+			"first segment across ISDs",
+			FromString("2-ffaa:0:200 1>1 1-ffaa:0:110 2>1 1-ffaa:0:112"),
+			[]*path.InfoField{against, construction},
+			[3]uint8{2, 2, 0},
+			[]Type{TypeCore, TypeDown},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			segments, err := SplitPath(testPath(t, test.seg, test.infos, test.seglen))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(segments) != len(test.want) {
+				t.Fatalf("want %d segments, have %d", len(test.want), len(segments))
+			}
+			for i, seg := range segments {
+				if have := seg.(Literal).Type; have != test.want[i] {
+					t.Errorf("segment %d: want type %s, have %s", i, test.want[i], have)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package segment
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Verdict is the decision of a filter stage about a single segment.
+type Verdict int
+
+const (
+	// VerdictAccepted means that the segment was kept by the stage.
+	VerdictAccepted Verdict = iota
+	// VerdictRejected means that the segment was removed by the stage.
+	VerdictRejected
+	// VerdictAdded means that the segment was created by the stage, e.g., a
+	// path that was enumerated from the input segments.
+	VerdictAdded
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case VerdictAccepted:
+		return "accepted"
+	case VerdictRejected:
+		return "rejected"
+	case VerdictAdded:
+		return "added"
+	}
+	return fmt.Sprintf("Verdict(%d)", int(v))
+}
+
+// Decision is the decision of a filter stage about a segment, with an
+// optional reason, e.g., the ACL entry that matched.
+type Decision struct {
+	Stage   string
+	Verdict Verdict
+	Reason  string
+}
+
+// Trace records the decisions of filter stages about segments, identified by
+// their fingerprints. Stages are named and can be nested, in which case the
+// name of a stage is prefixed by the names of its enclosing stages, separated
+// by a slash. All methods can be called on a nil Trace, in which case they do
+// nothing, such that filters can report to the Trace of a SegmentSet without
+// checking whether tracing is enabled.
+type Trace struct {
+	scope     []string
+	stages    []stageSummary
+	order     []string
+	segments  map[string]Segment
+	decisions map[string][]Decision
+	reasons   map[string]string
+	disclosed int
+}
+
+type stageSummary struct {
+	name     string
+	verdicts [3]int
+}
+
+// NewTrace returns an empty Trace.
+func NewTrace() *Trace {
+	return &Trace{
+		segments:  make(map[string]Segment),
+		decisions: make(map[string][]Decision),
+		reasons:   make(map[string]string),
+	}
+}
+
+// Begin opens a nested stage with the given name. Stages that are recorded
+// before the corresponding call to End are prefixed with the name.
+func (t *Trace) Begin(name string) {
+	if t == nil {
+		return
+	}
+	t.scope = append(t.scope, name)
+}
+
+// End closes the innermost stage that has been opened with Begin.
+func (t *Trace) End() {
+	if t == nil || len(t.scope) == 0 {
+		return
+	}
+	t.scope = t.scope[:len(t.scope)-1]
+}
+
+// Explain attaches a reason to the decision about a segment that is recorded
+// by the next call to Record.
+func (t *Trace) Explain(segment Segment, reason string) {
+	if t == nil {
+		return
+	}
+	t.reasons[segment.Fingerprint()] = reason
+}
+
+// Record records the decisions of a stage by comparing its input with its
+// output. Input segments that are contained in the output are accepted, the
+// others are rejected. Output segments that are not contained in the input
+// are added.
+func (t *Trace) Record(stage string, in, out SegmentSet) {
+	if t == nil {
+		return
+	}
+	name := strings.Join(append(t.scope[:len(t.scope):len(t.scope)], stage), "/")
+	summary := stageSummary{name: name}
+	inKeys := identities(in.Segments, Segment.Fingerprint)
+	outKeys := identities(out.Segments, Segment.Fingerprint)
+	record := func(segment Segment, verdict Verdict) {
+		fprint := segment.Fingerprint()
+		if _, ok := t.segments[fprint]; !ok {
+			t.segments[fprint] = segment
+			t.order = append(t.order, fprint)
+		}
+		t.decisions[fprint] = append(t.decisions[fprint], Decision{
+			Stage:   name,
+			Verdict: verdict,
+			Reason:  t.reasons[fprint],
+		})
+		summary.verdicts[verdict]++
+	}
+	for _, segment := range in.Dedup(Segment.Fingerprint).Segments {
+		if outKeys[segment.Fingerprint()] {
+			record(segment, VerdictAccepted)
+		} else {
+			record(segment, VerdictRejected)
+		}
+	}
+	for _, segment := range out.Dedup(Segment.Fingerprint).Segments {
+		if !inKeys[segment.Fingerprint()] {
+			record(segment, VerdictAdded)
+		}
+	}
+	t.stages = append(t.stages, summary)
+	t.reasons = make(map[string]string)
+}
+
+// Disclose records that the given number of segment literals has been
+// disclosed to the peer of a negotiation.
+func (t *Trace) Disclose(count int) {
+	if t == nil {
+		return
+	}
+	t.disclosed += count
+}
+
+// Disclosed returns the total number of segment literals that have been
+// disclosed to the peer of a negotiation.
+func (t *Trace) Disclosed() int {
+	if t == nil {
+		return 0
+	}
+	return t.disclosed
+}
+
+// Segments returns all segments that occur in the Trace, in the order in
+// which they first occurred.
+func (t *Trace) Segments() []Segment {
+	if t == nil {
+		return nil
+	}
+	segments := make([]Segment, len(t.order))
+	for i, fprint := range t.order {
+		segments[i] = t.segments[fprint]
+	}
+	return segments
+}
+
+// Decisions returns the recorded decisions about a segment, in the order in
+// which the stages were recorded.
+func (t *Trace) Decisions(segment Segment) []Decision {
+	if t == nil {
+		return nil
+	}
+	return t.decisions[segment.Fingerprint()]
+}
+
+// WriteReport writes a human-readable report of the Trace to w. The report
+// starts with a summary per stage and the number of disclosed segment
+// literals, if any, followed by the decisions per segment.
+func (t *Trace) WriteReport(w io.Writer) error {
+	if t == nil {
+		_, err := fmt.Fprintln(w, "no trace recorded")
+		return err
+	}
+	var b strings.Builder
+	fmt.Fprintln(&b, "stages:")
+	for _, stage := range t.stages {
+		fmt.Fprintf(&b, "  %s: %d accepted, %d rejected, %d added\n", stage.name,
+			stage.verdicts[VerdictAccepted], stage.verdicts[VerdictRejected], stage.verdicts[VerdictAdded])
+	}
+	if t.disclosed > 0 {
+		fmt.Fprintf(&b, "disclosed %d segment literals\n", t.disclosed)
+	}
+	fmt.Fprintln(&b, "segments:")
+	for _, fprint := range t.order {
+		fmt.Fprintf(&b, "  %s\n", t.segments[fprint])
+		for _, decision := range t.decisions[fprint] {
+			fmt.Fprintf(&b, "    %s: %s", decision.Stage, decision.Verdict)
+			if decision.Reason != "" {
+				fmt.Fprintf(&b, " (%s)", decision.Reason)
+			}
+			fmt.Fprintln(&b)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
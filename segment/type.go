@@ -0,0 +1,60 @@
+package segment
+
+import "fmt"
+
+// Type is the type of a segment literal as it is used in a SCION path.
+type Type uint8
+
+const (
+	// TypeUnspecified is used if the type of a literal is not known.
+	TypeUnspecified Type = iota
+	// TypeUp denotes an up-segment.
+	TypeUp
+	// TypeCore denotes a core-segment.
+	TypeCore
+	// TypeDown denotes a down-segment.
+	TypeDown
+	// TypePeering denotes a part of a path that uses a peering link.
+	TypePeering
+)
+
+var typeNames = []string{"unspecified", "up", "core", "down", "peering"}
+
+func (t Type) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return fmt.Sprintf("Type(%d)", uint8(t))
+}
+
+// TypeFromString parses the string representation of a segment type.
+func TypeFromString(str string) (Type, error) {
+	for i, name := range typeNames {
+		if name == str {
+			return Type(i), nil
+		}
+	}
+	return TypeUnspecified, fmt.Errorf("unknown segment type: %q", str)
+}
+
+// Direction is the direction in which a segment literal is traversed,
+// relative to the direction in which the segment was constructed by beaconing.
+type Direction uint8
+
+const (
+	// DirUnspecified is used if the direction of a literal is not known.
+	DirUnspecified Direction = iota
+	// DirConstruction denotes traversal in construction direction.
+	DirConstruction
+	// DirAgainstConstruction denotes traversal against construction direction.
+	DirAgainstConstruction
+)
+
+var directionNames = []string{"unspecified", "construction", "against-construction"}
+
+func (d Direction) String() string {
+	if int(d) < len(directionNames) {
+		return directionNames[d]
+	}
+	return fmt.Sprintf("Direction(%d)", uint8(d))
+}
@@ -0,0 +1,131 @@
+package conpass
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/mblarer/conpass/segment"
+)
+
+// Message describes a message that was sent during a simulated negotiation.
+type Message struct {
+	// Sender is the role of the agent that sent the message.
+	Sender segment.Role
+	// Size is the size of the message in bytes.
+	Size int
+}
+
+// SimulationResult is the outcome of a simulated negotiation.
+type SimulationResult struct {
+	// Initiator is the SegmentSet that the Initiator negotiated.
+	Initiator segment.SegmentSet
+	// Responder is the SegmentSet that the Responder consented to.
+	Responder segment.SegmentSet
+	// Messages are the messages that were exchanged, in order.
+	Messages []Message
+}
+
+// Rounds returns the number of messages that were exchanged.
+func (r SimulationResult) Rounds() int {
+	return len(r.Messages)
+}
+
+// Bytes returns the total size of the messages that were sent by the agent
+// with the given role.
+func (r SimulationResult) Bytes(sender segment.Role) int {
+	total := 0
+	for _, message := range r.Messages {
+		if message.Sender == sender {
+			total += message.Size
+		}
+	}
+	return total
+}
+
+// Simulate runs a negotiation between an Initiator and a Responder over
+// in-memory pipes, such that the outcome of a negotiation can be predicted
+// without any network. Consecutive writes of the same agent are counted as a
+// single message.
+func Simulate(ctx context.Context, client Initiator, server Responder) (SimulationResult, error) {
+	creqReader, creqWriter := io.Pipe()
+	srespReader, srespWriter := io.Pipe()
+	transcript := &transcript{}
+	clientStream := simulatedStream{
+		Reader: srespReader,
+		Writer: transcript.writer(creqWriter, segment.RoleInitiator),
+	}
+	serverStream := simulatedStream{
+		Reader: creqReader,
+		Writer: transcript.writer(srespWriter, segment.RoleResponder),
+	}
+
+	type outcome struct {
+		segset segment.SegmentSet
+		err    error
+	}
+	serverDone := make(chan outcome, 1)
+	go func() {
+		segset, err := server.NegotiateOverContext(ctx, serverStream)
+		if err != nil {
+			// unblock the client if it is still reading or writing
+			creqReader.CloseWithError(err)
+			srespWriter.CloseWithError(err)
+		} else {
+			srespWriter.Close()
+		}
+		serverDone <- outcome{segset, err}
+	}()
+	csegset, err := client.NegotiateOverContext(ctx, clientStream)
+	if err != nil {
+		// unblock the server if it is still reading or writing
+		srespReader.CloseWithError(err)
+		creqWriter.CloseWithError(err)
+	} else {
+		creqWriter.Close()
+	}
+	sresult := <-serverDone
+	if err == nil {
+		err = sresult.err
+	}
+	if err != nil {
+		return SimulationResult{}, err
+	}
+	return SimulationResult{
+		Initiator: csegset,
+		Responder: sresult.segset,
+		Messages:  transcript.messages,
+	}, nil
+}
+
+type simulatedStream struct {
+	io.Reader
+	io.Writer
+}
+
+// transcript records the messages that are written by both agents.
+type transcript struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+func (t *transcript) writer(w io.Writer, sender segment.Role) io.Writer {
+	return transcriptWriter{transcript: t, writer: w, sender: sender}
+}
+
+type transcriptWriter struct {
+	transcript *transcript
+	writer     io.Writer
+	sender     segment.Role
+}
+
+func (tw transcriptWriter) Write(p []byte) (int, error) {
+	t := tw.transcript
+	t.mutex.Lock()
+	if len(t.messages) == 0 || t.messages[len(t.messages)-1].Sender != tw.sender {
+		t.messages = append(t.messages, Message{Sender: tw.sender})
+	}
+	t.messages[len(t.messages)-1].Size += len(p)
+	t.mutex.Unlock()
+	return tw.writer.Write(p)
+}